@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/ashishb/asb/src/asb/pkg/cmdrunner"
+)
+
+func cacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cache volumes of the sandboxed tools",
+	}
+	cmd.AddCommand(cacheLsCmd())
+	cmd.AddCommand(cachePruneCmd())
+	cmd.AddCommand(cacheResetCmd())
+	return cmd
+}
+
+func cacheLsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls",
+		Short: "List the cache volumes with their size and when they were last used",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			volumes, err := cmdrunner.ListCacheVolumes(cmd.Context(), getProjectBackendType(cmd))
+			if err != nil {
+				log.Fatal().
+					Ctx(cmd.Context()).
+					Err(err).
+					Msg("Failed to list cache volumes")
+			}
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(writer, "VOLUME\tSCOPE\tTOOLS\tSIZE\tLAST USED")
+			for _, volume := range volumes {
+				_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", volume.Name, volume.Scope,
+					describeTools(volume.Tools), formatSize(volume.SizeBytes), formatLastUsed(volume.LastUsed))
+			}
+			_ = writer.Flush()
+		},
+	}
+}
+
+func cachePruneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove the cache volumes of a tool, e.g. to recover from a poisoned cache",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmdType := getToolCmdType(cmd, getStringFlagOrFail(cmd, "tool"))
+			removed, err := cmdrunner.PruneCacheVolumes(cmd.Context(), getProjectBackendType(cmd), cmdType)
+			printRemovedVolumes(removed, err)
+			if err != nil {
+				log.Fatal().
+					Ctx(cmd.Context()).
+					Err(err).
+					Msg("Failed to remove cache volumes")
+			}
+		},
+	}
+	_ = cmd.Flags().String("tool", "", "Tool whose cache volumes are removed, e.g. npm or uvx")
+	_ = cmd.MarkFlagRequired("tool")
+	return cmd
+}
+
+func cacheResetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reset",
+		Short: "Remove all the cache volumes",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			removed, err := cmdrunner.PruneCacheVolumes(cmd.Context(), getProjectBackendType(cmd), "")
+			printRemovedVolumes(removed, err)
+			if err != nil {
+				log.Fatal().
+					Ctx(cmd.Context()).
+					Err(err).
+					Msg("Failed to remove cache volumes")
+			}
+		},
+	}
+}
+
+// getProjectBackendType returns the backend for commands that are not tied to a tool
+func getProjectBackendType(cmd *cobra.Command) cmdrunner.BackendType {
+	settings := loadSettingsOrFail(cmd, getStringFlagOrFail(cmd, "directory"), "")
+	return getBackendType(cmd, settings)
+}
+
+// getToolCmdType accepts either the name of a tool command, e.g. "uvx", or a command type, e.g. "python_uvx"
+func getToolCmdType(cmd *cobra.Command, tool string) cmdrunner.CmdType {
+	if toolCmd, _, err := cmd.Root().Find([]string{tool}); err == nil && toolCmd.Annotations[_cmdTypeAnnotation] != "" {
+		return cmdrunner.CmdType(toolCmd.Annotations[_cmdTypeAnnotation])
+	}
+
+	cmdType, err := cmdrunner.ParseCmdType(tool)
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Invalid tool")
+	}
+	return cmdType
+}
+
+func printRemovedVolumes(removed []string, err error) {
+	if len(removed) == 0 && err == nil {
+		fmt.Println("No cache volumes removed")
+		return
+	}
+
+	for _, name := range removed {
+		fmt.Println("Removed " + name)
+	}
+}
+
+func describeTools(tools []cmdrunner.CmdType) string {
+	if len(tools) == 0 {
+		return "none"
+	}
+
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		names = append(names, string(tool))
+	}
+	return strings.Join(names, ",")
+}
+
+func formatSize(sizeBytes int64) string {
+	if sizeBytes < 0 {
+		return "unknown"
+	}
+
+	const unit = 1024
+	if sizeBytes < unit {
+		return fmt.Sprintf("%d B", sizeBytes)
+	}
+
+	size, exponent := float64(sizeBytes)/unit, 0
+	for size >= unit && exponent < 3 {
+		size /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", size, "KMGT"[exponent])
+}
+
+func formatLastUsed(lastUsed time.Time) string {
+	if lastUsed.IsZero() {
+		return "unknown"
+	}
+	return lastUsed.Local().Format(time.DateTime)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"cmp"
+	"errors"
 	"os"
 	"path/filepath"
 	"slices"
@@ -9,16 +11,21 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
-	"github.com/ashishb/asb/src/asb/internal/cmdrunner"
+	"github.com/ashishb/asb/src/asb/internal/config"
+	"github.com/ashishb/asb/src/asb/pkg/cmdrunner"
 )
 
+// Annotation of the tool commands that holds their command type
+const _cmdTypeAnnotation = "asb.cmdType"
+
 func createCmd(cmd *cobra.Command, cmdType cmdrunner.CmdType) *cobra.Command {
 	cmd.FParseErrWhitelist.UnknownFlags = true
+	cmd.Annotations = map[string]string{_cmdTypeAnnotation: string(cmdType)}
 
 	// This convoluted setup passes help properly to sub-command, "cobra CLI framework"
 	// has no good support to handle this
 	cmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
-		// E.g asb uvx yamllint --help
+		// E.g. asb --help uvx, as the flags after the tool name go to the tool, see separateToolArgs
 		log.Debug().
 			Ctx(cmd.Context()).
 			Str("name", cmd.Name()).
@@ -27,19 +34,57 @@ func createCmd(cmd *cobra.Command, cmdType cmdrunner.CmdType) *cobra.Command {
 		cmd.Run(cmd, args)
 	})
 	cmd.Run = func(cmd *cobra.Command, args []string) {
-		options := getCmdConfig(cmd, args)
-		cfg := cmdrunner.NewConfig(cmdType, options...)
-		err := cmdrunner.RunCmd(cmd.Context(), cfg)
-		if err != nil {
-			log.Fatal().
-				Ctx(cmd.Context()).
-				Err(err).
-				Msg("Error running command")
-		}
+		runTool(cmd, cmdType, args)
 	}
 	return cmd
 }
 
+// runTool runs the tool with the given args inside the sandbox, or explains the sandbox
+func runTool(cmd *cobra.Command, cmdType cmdrunner.CmdType, args []string, extraOptions ...cmdrunner.Option) {
+	options := append(getCmdConfig(cmd, cmdType, args), extraOptions...)
+	cfg, err := cmdrunner.NewConfig(cmdType, options...)
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Invalid tool")
+	}
+
+	if isExplainCmd(cmd) || getBoolFlagOrFail(cmd, "dry-run") {
+		explain(cmd, cfg)
+		return
+	}
+
+	result, err := cmdrunner.RunCmd(cmd.Context(), cfg)
+	var exitErr *cmdrunner.ExitError
+	if errors.As(err, &exitErr) {
+		// Exit with the same code as the command, the way "docker run" does
+		log.Debug().
+			Ctx(cmd.Context()).
+			Int("exitCode", exitErr.Code).
+			Dur("duration", result.Duration).
+			Msg("Command failed")
+		os.Exit(exitErr.Code)
+	}
+	if errors.Is(err, cmdrunner.ErrDockerUnavailable) {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Docker or Podman has to be installed and running")
+	}
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Error running command")
+	}
+
+	log.Debug().
+		Ctx(cmd.Context()).
+		Dur("duration", result.Duration).
+		Msg("Command finished")
+}
+
 func getCwdOrFail() string {
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -61,6 +106,17 @@ func getStringFlagOrFail(cmd *cobra.Command, name string) string {
 	return value
 }
 
+func getStringArrayFlagOrFail(cmd *cobra.Command, name string) []string {
+	values, err := cmd.Flags().GetStringArray(name)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str("flagName", name).
+			Msg("Failed to fetch flag")
+	}
+	return values
+}
+
 func getBoolFlagOrFail(cmd *cobra.Command, name string) bool {
 	value, err := cmd.Flags().GetBool(name)
 	if err != nil {
@@ -72,23 +128,13 @@ func getBoolFlagOrFail(cmd *cobra.Command, name string) bool {
 	return value
 }
 
-func getCmdConfig(cmd *cobra.Command, args []string) []cmdrunner.Option {
+func getCmdConfig(cmd *cobra.Command, cmdType cmdrunner.CmdType, args []string) []cmdrunner.Option {
 	directory := getStringFlagOrFail(cmd, "directory")
-	enableNetwork := !getBoolFlagOrFail(cmd, "no-network")
-	readWrite := getBoolFlagOrFail(cmd, "read-write")
-	readOnly := getBoolFlagOrFail(cmd, "read-only")
-	noDiskAccess := getBoolFlagOrFail(cmd, "no-disk-access")
-	loadEnv := getBoolFlagOrFail(cmd, "load-env")
-	// Note that, readWrite is true by default
-	if noDiskAccess || readOnly {
-		readWrite = false
-	}
+	settings := loadSettingsOrFail(cmd, directory, cmdType)
 
-	if readOnly && noDiskAccess {
-		log.Fatal().
-			Ctx(cmd.Context()).
-			Msg("Both read-only and no-disk-access flags cannot be enabled together")
-	}
+	// CLI flags always win over the values from the config file
+	loadEnv := getBoolFlagOrConfig(cmd, "load-env", settings.LoadEnv)
+	runAsRoot := getBoolFlagOrConfig(cmd, "run-as-root", settings.RunAsRoot)
 
 	log.Debug().
 		Ctx(cmd.Context()).
@@ -98,27 +144,34 @@ func getCmdConfig(cmd *cobra.Command, args []string) []cmdrunner.Option {
 		Msg("Running command")
 
 	options := []cmdrunner.Option{
+		cmdrunner.SetBackendType(getBackendType(cmd, settings)),
 		cmdrunner.SetWorkingDir(directory),
-		cmdrunner.SetArgs(getCmdArgs(cmd)),
-		cmdrunner.SetRunAsNonRoot(true),
+		cmdrunner.SetArgs(args),
+		cmdrunner.SetImageDigests(loadLockOrFail(cmd, directory).Images),
+		cmdrunner.SetToolVersion(getStringFlagOrConfig(cmd, "tool-version", settings.ToolVersion)),
+		cmdrunner.SetRunAsNonRoot(!runAsRoot),
+		cmdrunner.SetPolicy(loadPolicyOrFail(cmd, cmdType)),
+		cmdrunner.SetInsecure(getBoolFlagOrConfig(cmd, "insecure", settings.Insecure)),
+		cmdrunner.SetReadOnlyRootFS(getBoolFlagOrConfig(cmd, "read-only-rootfs", settings.ReadOnlyFS)),
+		cmdrunner.SetAllowGitWrite(getBoolFlagOrConfig(cmd, "allow-git-write", settings.AllowGitWrite)),
+		cmdrunner.AddReadOnlyPaths(settings.ReadOnlyPaths),
+		cmdrunner.SetAuditLog(getAuditLogFileOrFail(cmd)),
+		cmdrunner.AddSensitivePaths(settings.SensitivePaths),
+		cmdrunner.AllowSensitivePaths(loadUserConfigOrFail(cmd).AllowSensitivePaths),
 	}
+	options = append(options, getDiskAccessOptions(cmd, settings)...)
+	options = append(options,
+		cmdrunner.SetResourceLimits(getResourceLimits(cmd, settings)),
+		cmdrunner.SetCacheScope(getCacheScope(cmd, settings)),
+		cmdrunner.SetReadOnlyCache(getBoolFlagOrConfig(cmd, "read-only-cache", settings.ReadOnlyCache)))
 
-	if readWrite {
-		options = append(options, cmdrunner.SetMountWorkingDirReadWrite(true))
-	} else if readOnly {
-		options = append(options, cmdrunner.SetMountWorkingDirReadOnly(true))
-	} else if noDiskAccess {
-		options = append(options,
-			cmdrunner.SetMountWorkingDirReadOnly(false),
-			cmdrunner.SetMountWorkingDirReadWrite(false),
-		)
-	}
-
-	networkType := cmdrunner.NetworkNone
-	if enableNetwork {
-		networkType = cmdrunner.NetworkHost
+	publishedPorts := getPublishedPorts(cmd, settings)
+	if networkType := getNetworkType(cmd, settings, len(publishedPorts) > 0); networkType != "" {
+		options = append(options, cmdrunner.SetNetworkType(networkType))
 	}
-	options = append(options, cmdrunner.SetNetworkType(networkType))
+	options = append(options,
+		cmdrunner.AddAllowedDomains(settings.AllowedDomains),
+		cmdrunner.AddPublishedPorts(publishedPorts))
 
 	if loadEnv {
 		envFile := filepath.Join(directory, ".env")
@@ -130,18 +183,198 @@ func getCmdConfig(cmd *cobra.Command, args []string) []cmdrunner.Option {
 			options = append(options, cmdrunner.SetLoadDotEnv(true))
 		}
 	}
+
+	for _, mount := range settings.Mounts {
+		options = append(options, cmdrunner.AddMount(mount.Source, mount.Target, mount.ReadOnly))
+	}
+	if len(settings.Env) > 0 {
+		options = append(options, cmdrunner.SetEnv(settings.Env))
+	}
 	return options
 }
 
-func getCmdArgs(cmd *cobra.Command) []string {
-	i1 := slices.Index(os.Args, cmd.Use)
-	if i1 == -1 {
+// getNetworkType returns the network set via flags or config, or empty to use the tool's default
+func getNetworkType(cmd *cobra.Command, settings config.Settings, publishesPorts bool) cmdrunner.NetworkType {
+	if getBoolFlagOrFail(cmd, "no-network") {
+		return cmdrunner.NetworkNone
+	}
+
+	network := getStringFlagOrFail(cmd, "network")
+	if !cmd.Flags().Changed("network") {
+		if getBoolFlagOrConfig(cmd, "no-network", settings.NoNetwork) {
+			return cmdrunner.NetworkNone
+		}
+
+		switch {
+		case settings.Network != nil:
+			network = *settings.Network
+		case publishesPorts:
+			// Publishing ports only works with the bridge network
+			network = string(cmdrunner.NetworkBridge)
+		default:
+			return ""
+		}
+	}
+
+	networkType, err := cmdrunner.ParseNetworkType(network)
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Invalid network type")
+	}
+	return networkType
+}
+
+func getBackendType(cmd *cobra.Command, settings config.Settings) cmdrunner.BackendType {
+	backendType, err := cmdrunner.ParseBackendType(getStringFlagOrConfig(cmd, "backend", settings.Backend))
+	if err != nil {
 		log.Fatal().
 			Ctx(cmd.Context()).
-			Msgf("Could not find command %q in args %q", cmd.Use, strings.Join(os.Args, " "))
+			Err(err).
+			Msg("Invalid backend")
+	}
+	return backendType
+}
+
+func getCacheScope(cmd *cobra.Command, settings config.Settings) cmdrunner.CacheScope {
+	scopeType := getStringFlagOrConfig(cmd, "cache-scope", settings.CacheScope)
+	zone := getStringFlagOrConfig(cmd, "cache-zone", settings.CacheZone)
+	if cmd.Flags().Changed("cache-scope") && !cmd.Flags().Changed("cache-zone") && scopeType != "zone" {
+		// An explicit scope overrides the zone coming from the config file
+		zone = ""
+	}
+
+	scope, err := cmdrunner.ParseCacheScope(scopeType, zone)
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Invalid cache scope")
 	}
+	return scope
+}
+
+func getPublishedPorts(cmd *cobra.Command, settings config.Settings) []cmdrunner.PortMapping {
+	values := getStringArrayFlagOrFail(cmd, "publish")
+	mappings := make([]cmdrunner.PortMapping, 0, len(values)+len(settings.Publish))
+	for _, value := range slices.Concat(settings.Publish, values) {
+		mapping, err := cmdrunner.ParsePortMapping(value)
+		if err != nil {
+			log.Fatal().
+				Ctx(cmd.Context()).
+				Err(err).
+				Msg("Invalid port mapping")
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings
+}
 
-	// Skip the first two args (program name, "npm" command)
-	cmdArgs := os.Args[i1+1:]
-	return cmdArgs
+// getDiskAccessOptions returns the options for the disk access set via flags or config,
+// only the writable paths are returned if neither sets it, so that the tool's default applies
+func getDiskAccessOptions(cmd *cobra.Command, settings config.Settings) []cmdrunner.Option {
+	isSet := func(name string) bool { return cmd.Flags().Changed(name) }
+	writablePaths := slices.Concat(settings.WritablePaths, getStringArrayFlagOrFail(cmd, "writable-path"))
+	options := []cmdrunner.Option{cmdrunner.AddWritablePaths(writablePaths)}
+	if !isSet("read-write") && !isSet("read-only") && !isSet("no-disk-access") && !isSet("writable-paths-only") &&
+		!isSet("writable-path") && settings.ReadOnly == nil && settings.NoDiskAccess == nil &&
+		settings.WritablePathsOnly == nil {
+		return options
+	}
+
+	readWrite := getBoolFlagOrFail(cmd, "read-write")
+	readOnly := getBoolFlagOrConfig(cmd, "read-only", settings.ReadOnly)
+	noDiskAccess := getBoolFlagOrConfig(cmd, "no-disk-access", settings.NoDiskAccess)
+	writablePathsOnly := getBoolFlagOrConfig(cmd, "writable-paths-only", settings.WritablePathsOnly) ||
+		isSet("writable-path")
+	if readWrite && cmd.Flags().Changed("read-write") {
+		// An explicit -w overrides read-only/no-disk-access/writable-paths-only coming from the config file
+		readOnly = readOnly && cmd.Flags().Changed("read-only")
+		noDiskAccess = noDiskAccess && cmd.Flags().Changed("no-disk-access")
+		writablePathsOnly = writablePathsOnly && (isSet("writable-paths-only") || isSet("writable-path"))
+	}
+
+	// Note that, readWrite is true by default
+	if noDiskAccess || readOnly || writablePathsOnly {
+		readWrite = false
+	}
+
+	if readOnly && noDiskAccess {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Msg("Both read-only and no-disk-access flags cannot be enabled together")
+	}
+	if writablePathsOnly && (readOnly || noDiskAccess) {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Msg("writable-paths-only cannot be enabled together with read-only or no-disk-access")
+	}
+
+	if readWrite {
+		return append(options, cmdrunner.SetMountWorkingDirReadWrite(true))
+	} else if readOnly {
+		return append(options, cmdrunner.SetMountWorkingDirReadOnly(true))
+	} else if writablePathsOnly {
+		return append(options, cmdrunner.SetMountWorkingDirWritablePaths(true))
+	} else if noDiskAccess {
+		return append(options,
+			cmdrunner.SetMountWorkingDirReadOnly(false),
+			cmdrunner.SetMountWorkingDirReadWrite(false),
+		)
+	}
+	return options
+}
+
+// separateToolArgs puts the args that follow the name of a tool command after "--", so that cobra passes
+// them to the tool as is. Only the flags before the tool name configure the sandbox, else a flag of the tool
+// like "--dry-run" in "asb npm publish --dry-run", or "-p" in "asb npx -p typescript tsc", would be taken by asb.
+func separateToolArgs(rootCmd *cobra.Command, args []string) []string {
+	cmd := rootCmd
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return args
+		}
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			if isFlagWithSeparateValue(cmd, arg) {
+				i++
+			}
+			continue
+		}
+
+		index := slices.IndexFunc(cmd.Commands(), func(sub *cobra.Command) bool { return sub.Name() == arg })
+		if index == -1 {
+			return args
+		}
+		cmd = cmd.Commands()[index]
+		if cmd.Annotations[_cmdTypeAnnotation] != "" {
+			return slices.Concat(args[:i+1], []string{"--"}, args[i+1:])
+		}
+	}
+	return args
+}
+
+// isFlagWithSeparateValue returns true if the flag of the command takes its value from the next arg,
+// e.g. "-d" in "-d ~/src/repo1", but not "--directory=~/src/repo1" or "-n"
+func isFlagWithSeparateValue(cmd *cobra.Command, arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+	if name, ok := strings.CutPrefix(arg, "--"); ok {
+		flag := cmp.Or(cmd.Flags().Lookup(name), cmd.PersistentFlags().Lookup(name), cmd.InheritedFlags().Lookup(name))
+		return flag != nil && flag.NoOptDefVal == ""
+	}
+
+	// Shorthands can be grouped, e.g. "-nr", and the value of the last one can follow it, e.g. "-d/src"
+	shorthands := arg[1:]
+	for i, shorthand := range shorthands {
+		flag := cmp.Or(cmd.Flags().ShorthandLookup(string(shorthand)),
+			cmd.PersistentFlags().ShorthandLookup(string(shorthand)),
+			cmd.InheritedFlags().ShorthandLookup(string(shorthand)))
+		if flag != nil && flag.NoOptDefVal == "" {
+			return i == len(shorthands)-1
+		}
+	}
+	return false
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSeparateToolArgs(t *testing.T) {
+	// Keeps the user config, and the tools it adds, out of the test
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	rootCmd := getRootCmd()
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "tool args",
+			args: []string{"npm", "install"},
+			want: []string{"npm", "--", "install"},
+		},
+		{
+			name: "flag of the tool with the shorthand of an asb flag",
+			args: []string{"npx", "-p", "typescript", "tsc"},
+			want: []string{"npx", "--", "-p", "typescript", "tsc"},
+		},
+		{
+			name: "asb flag before the tool",
+			args: []string{"-p", "5173", "npm", "run", "dev", "--", "--host", "0.0.0.0"},
+			want: []string{"-p", "5173", "npm", "--", "run", "dev", "--", "--host", "0.0.0.0"},
+		},
+		{
+			name: "asb flag value that is a tool name",
+			args: []string{"-d", "npm", "npx", "-d", "x"},
+			want: []string{"-d", "npm", "npx", "--", "-d", "x"},
+		},
+		{
+			name: "asb flags with attached values",
+			args: []string{"--directory=npm", "-d/src", "-nr", "npm", "ci"},
+			want: []string{"--directory=npm", "-d/src", "-nr", "npm", "--", "ci"},
+		},
+		{
+			name: "grouped shorthands ending with a flag that takes a value",
+			args: []string{"-nd", "/src", "npm", "ci"},
+			want: []string{"-nd", "/src", "npm", "--", "ci"},
+		},
+		{
+			name: "dry run of asb",
+			args: []string{"--dry-run", "npm", "publish"},
+			want: []string{"--dry-run", "npm", "--", "publish"},
+		},
+		{
+			name: "dry run of the tool",
+			args: []string{"npm", "publish", "--dry-run"},
+			want: []string{"npm", "--", "publish", "--dry-run"},
+		},
+		{
+			name: "limits of asb",
+			args: []string{"--timeout", "30m", "--memory=4g", "--cpus", "2", "cargo", "build"},
+			want: []string{"--timeout", "30m", "--memory=4g", "--cpus", "2", "cargo", "--", "build"},
+		},
+		{
+			name: "limits of the tool",
+			args: []string{"npx", "mocha", "--timeout", "5000", "--memory", "x"},
+			want: []string{"npx", "--", "mocha", "--timeout", "5000", "--memory", "x"},
+		},
+		{
+			name: "explain",
+			args: []string{"explain", "--format", "json", "npx", "-p", "typescript"},
+			want: []string{"explain", "--format", "json", "npx", "--", "-p", "typescript"},
+		},
+		{
+			name: "tool without args",
+			args: []string{"-n", "npm"},
+			want: []string{"-n", "npm", "--"},
+		},
+		{
+			name: "run keeps its own separator",
+			args: []string{"run", "--image", "alpine", "--", "ls", "-p"},
+			want: []string{"run", "--image", "alpine", "--", "ls", "-p"},
+		},
+		{
+			name: "other command",
+			args: []string{"cache", "prune", "--tool", "npm"},
+			want: []string{"cache", "prune", "--tool", "npm"},
+		},
+		{
+			name: "unknown command",
+			args: []string{"nope", "npm"},
+			want: []string{"nope", "npm"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := separateToolArgs(rootCmd, tt.args); !slices.Equal(got, tt.want) {
+				t.Errorf("separateToolArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/ashishb/asb/src/asb/internal/config"
+	"github.com/ashishb/asb/src/asb/pkg/cmdrunner"
+)
+
+// loadSettingsOrFail loads the layered user and project config for the given tool
+func loadSettingsOrFail(cmd *cobra.Command, directory string, cmdType cmdrunner.CmdType) config.Settings {
+	cfg, err := config.Load(directory)
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Failed to load config")
+	}
+
+	if cfg.Path() != "" {
+		log.Debug().
+			Ctx(cmd.Context()).
+			Str("configFile", cfg.Path()).
+			Msg("Using config")
+	}
+	return cfg.ForTool(string(cmdType))
+}
+
+// loadLockOrFail loads the lock file of the project in the given directory
+func loadLockOrFail(cmd *cobra.Command, directory string) *config.Lock {
+	lock, err := config.LoadLock(directory)
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Failed to load lock file")
+	}
+	return lock
+}
+
+// loadUserConfigOrFail loads the user config, for the keys that are not allowed in the project config
+func loadUserConfigOrFail(cmd *cobra.Command) *config.Config {
+	userConfig, err := config.LoadUser()
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Failed to load user config")
+	}
+	return userConfig
+}
+
+// getAuditLogFileOrFail returns the audit log file set in the user config, else the default one
+func getAuditLogFileOrFail(cmd *cobra.Command) string {
+	if userConfig := loadUserConfigOrFail(cmd); userConfig.AuditLog != "" {
+		return userConfig.AuditLog
+	}
+
+	file, err := cmdrunner.DefaultAuditLogFile()
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Failed to get audit log file")
+	}
+	return file
+}
+
+// loadPolicyOrFail loads the system-wide policy for the given tool
+func loadPolicyOrFail(cmd *cobra.Command, cmdType cmdrunner.CmdType) cmdrunner.Policy {
+	policy, err := config.LoadPolicy()
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Failed to load policy")
+	}
+
+	rules := policy.ForTool(string(cmdType))
+	return cmdrunner.Policy{
+		Source:       policy.Path(),
+		NoNetwork:    rules.NoNetwork,
+		ReadOnly:     rules.ReadOnly,
+		NoLoadEnv:    rules.NoLoadEnv,
+		NoInsecure:   rules.NoInsecure,
+		DeniedMounts: rules.DeniedMounts,
+	}
+}
+
+// getBoolFlagOrConfig returns the flag value if it was explicitly set on the command line,
+// else the config value if set, else the flag's default value
+func getBoolFlagOrConfig(cmd *cobra.Command, name string, configValue *bool) bool {
+	if cmd.Flags().Changed(name) || configValue == nil {
+		return getBoolFlagOrFail(cmd, name)
+	}
+	return *configValue
+}
+
+// getResourceLimits returns the limits set via flags or config, unset limits are left as zero
+// so that the tool's defaults apply
+func getResourceLimits(cmd *cobra.Command, settings config.Settings) cmdrunner.ResourceLimits {
+	var limits cmdrunner.ResourceLimits
+	var err error
+	if memory := getStringFlagOrConfig(cmd, "memory", settings.Memory); memory != "" {
+		if limits.MemoryBytes, err = cmdrunner.ParseMemorySize(memory); err != nil {
+			log.Fatal().
+				Ctx(cmd.Context()).
+				Err(err).
+				Msg("Invalid memory limit")
+		}
+	}
+
+	if timeout := getStringFlagOrConfig(cmd, "timeout", settings.Timeout); timeout != "" {
+		if limits.Timeout, err = time.ParseDuration(timeout); err != nil {
+			log.Fatal().
+				Ctx(cmd.Context()).
+				Err(err).
+				Msg("Invalid timeout")
+		}
+	}
+
+	limits.CPUs, err = cmd.Flags().GetFloat64("cpus")
+	if err == nil && !cmd.Flags().Changed("cpus") && settings.CPUs != nil {
+		limits.CPUs = *settings.CPUs
+	}
+
+	limits.PidsLimit, err = cmd.Flags().GetInt64("pids-limit")
+	if err == nil && !cmd.Flags().Changed("pids-limit") && settings.PidsLimit != nil {
+		limits.PidsLimit = *settings.PidsLimit
+	}
+
+	if limits.CPUs < 0 || limits.PidsLimit < 0 {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Msg("CPU and pids limits cannot be negative")
+	}
+	return limits
+}
+
+// getStringFlagOrConfig is like getBoolFlagOrConfig but for flags whose value is read as a string
+func getStringFlagOrConfig(cmd *cobra.Command, name string, configValue *string) string {
+	if cmd.Flags().Changed(name) || configValue == nil {
+		return cmd.Flags().Lookup(name).Value.String()
+	}
+	return *configValue
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/ashishb/asb/src/asb/internal/config"
+	"github.com/ashishb/asb/src/asb/pkg/cmdrunner"
+)
+
+func doctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that this machine can run the sandbox, with hints on how to fix it",
+		Long: "Check that this machine can run the sandbox, with hints on how to fix it.\n" +
+			"Checks the config files, the container backend, its version and user namespace mode, the free disk\n" +
+			"space for the cache volumes, the tool images and the config directories of the coding agents.\n" +
+			"Exits with a non-zero exit code if any check fails.",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			directory := getStringFlagOrFail(cmd, "directory")
+			// A broken config is reported as a failed check, so that the other checks still run
+			configCheck, settings := checkConfig(directory)
+			checks := slices.Concat([]cmdrunner.Check{configCheck},
+				cmdrunner.Diagnose(cmd.Context(), getBackendType(cmd, settings), directory))
+
+			var err error
+			switch format := getStringFlagOrFail(cmd, "format"); format {
+			case "json":
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				err = encoder.Encode(checks)
+			case "text":
+				err = writeChecks(checks)
+			default:
+				log.Fatal().
+					Ctx(cmd.Context()).
+					Msgf("Unsupported format %q, supported formats are \"text\" and \"json\"", format)
+			}
+
+			if err != nil {
+				log.Fatal().
+					Ctx(cmd.Context()).
+					Err(err).
+					Msg("Error writing checks")
+			}
+
+			if slices.ContainsFunc(checks, func(check cmdrunner.Check) bool { return check.Status == cmdrunner.CheckFail }) {
+				os.Exit(1)
+			}
+		},
+	}
+	_ = cmd.Flags().String("format", "text", "Output format, one of \"text\" or \"json\"")
+	return cmd
+}
+
+// checkConfig loads the config of the project in the directory, the settings are empty if it cannot be loaded
+func checkConfig(directory string) (cmdrunner.Check, config.Settings) {
+	cfg, err := config.Load(directory)
+	if err != nil {
+		return cmdrunner.Check{
+			Name:        "config",
+			Status:      cmdrunner.CheckFail,
+			Message:     err.Error(),
+			Remediation: "Fix the config file named in the error, the tools cannot run until then",
+		}, config.Settings{}
+	}
+
+	message := "No config file, using the defaults"
+	if cfg.Path() != "" {
+		message = "Loaded " + cfg.Path()
+	}
+	return cmdrunner.Check{Name: "config", Status: cmdrunner.CheckPass, Message: message}, cfg.ForTool("")
+}
+
+func writeChecks(checks []cmdrunner.Check) error {
+	lines := make([]string, 0, len(checks))
+	for _, check := range checks {
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", strings.ToUpper(string(check.Status)), check.Name, check.Message))
+		if check.Remediation != "" {
+			lines = append(lines, "       "+check.Remediation)
+		}
+	}
+	_, err := fmt.Println(strings.Join(lines, "\n"))
+	return err
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/ashishb/asb/src/asb/pkg/cmdrunner"
+	"github.com/ashishb/asb/src/asb/pkg/registry"
+)
+
+const _explainCmdName = "explain"
+
+func explainCmd(toolRegistry *registry.Registry) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   _explainCmdName,
+		Short: "Explain the sandbox a command would run in, without running it",
+		Long: "Explain the sandbox a command would run in, without running it\n" +
+			"E.g. asb explain npm install",
+	}
+	_ = cmd.PersistentFlags().String("format", "text", "Output format, one of \"text\" or \"json\"")
+	addToolCmds(cmd, toolRegistry)
+	return cmd
+}
+
+func isExplainCmd(cmd *cobra.Command) bool {
+	return cmd.Parent() != nil && cmd.Parent().Name() == _explainCmdName
+}
+
+func explain(cmd *cobra.Command, cfg cmdrunner.Config) {
+	explanation, err := cmdrunner.Explain(cfg)
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Error explaining command")
+	}
+
+	format := "text"
+	if cmd.Flags().Lookup("format") != nil {
+		format = getStringFlagOrFail(cmd, "format")
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(explanation)
+	case "text":
+		err = writeExplanation(os.Stdout, explanation)
+	default:
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Msgf("Unsupported format %q, supported formats are \"text\" and \"json\"", format)
+	}
+
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Msg("Error writing explanation")
+	}
+}
+
+func writeExplanation(w io.Writer, e cmdrunner.Explanation) error {
+	user := e.User
+	if user == "" {
+		user = "root"
+	}
+
+	lines := []string{
+		"Tool:        " + string(e.CmdType),
+		"Backend:     " + string(e.Backend),
+		"Image:       " + e.Image,
+	}
+	if e.ToolVersion != nil {
+		lines = append(lines, fmt.Sprintf("Version:     %s (%s requested by %s)",
+			e.ToolVersion.Selected, e.ToolVersion.Requested, e.ToolVersion.Source))
+	}
+	if len(e.Entrypoint) > 0 {
+		lines = append(lines, "Entrypoint:  "+strings.Join(e.Entrypoint, " "))
+	}
+	lines = append(lines,
+		"Command:     "+strings.Join(e.Command, " "),
+		"Working dir: "+e.WorkingDir,
+		"User:        "+user,
+		"Network:     "+e.Network)
+	if len(e.AllowedDomains) > 0 {
+		lines = append(lines, "Allowed:     "+strings.Join(e.AllowedDomains, ", "))
+	}
+	if len(e.PublishedPorts) > 0 {
+		lines = append(lines, "Published:   "+strings.Join(e.PublishedPorts, ", "))
+	}
+
+	lines = append(lines, "", "Mounts:")
+	for _, mount := range e.Mounts {
+		mode := "RW"
+		if mount.ReadOnly {
+			mode = "RO"
+		}
+		source := mount.Source
+		if source == "" {
+			source = string(mount.Type)
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s -> %s (%s)", mode, source, mount.Target, mount.Purpose))
+	}
+
+	lines = append(lines, "", "Referenced files:")
+	lines = append(lines, indentOrNone(e.ReferencedFiles)...)
+	lines = append(lines, "", "Env files:")
+	lines = append(lines, indentOrNone(e.EnvFiles)...)
+	lines = append(lines, "", "Env:")
+	lines = append(lines, indentOrNone(e.Env)...)
+
+	lines = append(lines, "", "Limits:")
+	lines = append(lines, indentOrNone(describeResources(e.Resources))...)
+
+	lines = append(lines, "", "Security:")
+	if e.Security.Hardened {
+		lines = append(lines,
+			"  Dropped capabilities: "+strings.Join(e.Security.CapDrop, ", "),
+			"  Added capabilities:   "+strings.Join(e.Security.CapAdd, ", "),
+			"  Security options:     no-new-privileges, seccomp profile")
+	} else {
+		lines = append(lines, "  Hardened profile disabled via --insecure")
+	}
+	if e.Security.ReadOnlyRootFS {
+		lines = append(lines, "  Read-only root filesystem")
+	}
+
+	lines = append(lines, "", "Equivalent command:", e.CLICommand)
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+func describeResources(resources cmdrunner.ExplainedResources) []string {
+	result := make([]string, 0)
+	if resources.MemoryBytes > 0 {
+		result = append(result, fmt.Sprintf("memory: %d MiB", resources.MemoryBytes>>20))
+	}
+	if resources.CPUs > 0 {
+		result = append(result, fmt.Sprintf("cpus: %g", resources.CPUs))
+	}
+	if resources.PidsLimit > 0 {
+		result = append(result, fmt.Sprintf("pids: %d", resources.PidsLimit))
+	}
+	if resources.Timeout != "" {
+		result = append(result, "timeout: "+resources.Timeout)
+	}
+	return result
+}
+
+func indentOrNone(values []string) []string {
+	if len(values) == 0 {
+		return []string{"  (none)"}
+	}
+
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		result = append(result, "  "+value)
+	}
+	return result
+}
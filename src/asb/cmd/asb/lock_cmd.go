@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/ashishb/asb/src/asb/internal/config"
+	"github.com/ashishb/asb/src/asb/pkg/cmdrunner"
+)
+
+func lockCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Pin the images of the tools to their digests in " + config.LockFileName,
+		Long: "Pin the images of the tools to their digests in " + config.LockFileName + ", so that everyone\n" +
+			"working on the project runs the same images. The images are the ones for the toolchain versions\n" +
+			"requested by the project files. Only the images that are not pinned yet are resolved,\n" +
+			"unless --update is passed.",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			directory := getStringFlagOrFail(cmd, "directory")
+			lock := loadLockOrFail(cmd, directory)
+			images, err := cmdrunner.ToolImages(directory)
+			if err != nil {
+				log.Fatal().
+					Ctx(cmd.Context()).
+					Err(err).
+					Msg("Failed to select the tool images")
+			}
+			if getBoolFlagOrFail(cmd, "update") {
+				// Also drops the images of tools that no longer exist
+				lock.Images = make(map[string]string, len(images))
+			}
+
+			unpinned := slices.DeleteFunc(slices.Clone(images), func(image string) bool {
+				return lock.Images[image] != ""
+			})
+			if len(unpinned) == 0 {
+				fmt.Println("All images are already pinned in " + lock.Path())
+				return
+			}
+
+			digests, err := cmdrunner.ResolveImageDigests(cmd.Context(), getProjectBackendType(cmd), unpinned)
+			if err != nil {
+				log.Fatal().
+					Ctx(cmd.Context()).
+					Err(err).
+					Msg("Failed to resolve image digests")
+			}
+
+			maps.Copy(lock.Images, digests)
+			if err = lock.Save(); err != nil {
+				log.Fatal().
+					Ctx(cmd.Context()).
+					Err(err).
+					Msg("Failed to save lock file")
+			}
+
+			for _, image := range unpinned {
+				fmt.Printf("Pinned %s to %s\n", image, digests[image])
+			}
+			fmt.Println("Wrote " + lock.Path())
+		},
+	}
+
+	_ = cmd.Flags().Bool("update", false, "Resolve the digests of all the images again")
+	return cmd
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/ashishb/asb/src/asb/pkg/cmdrunner"
+)
+
+func logCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "log",
+		Short: "Show the audit log of the sandboxed runs",
+		Long: "Show the audit log of the sandboxed runs, oldest first.\n" +
+			"Only the runs in the directory passed via --directory, or below it, are shown if it is passed.\n" +
+			"E.g. asb log --tool npx --since 2026-01-31",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			filter := cmdrunner.AuditFilter{
+				Since: getTimeFlagOrFail(cmd, "since", false),
+				Until: getTimeFlagOrFail(cmd, "until", true),
+			}
+			if tool := getStringFlagOrFail(cmd, "tool"); tool != "" {
+				filter.CmdType = getToolCmdType(cmd, tool)
+			}
+			if cmd.Flags().Changed("directory") {
+				directory, err := filepath.Abs(getStringFlagOrFail(cmd, "directory"))
+				if err != nil {
+					log.Fatal().
+						Ctx(cmd.Context()).
+						Err(err).
+						Msg("Invalid directory")
+				}
+				filter.WorkingDir = directory
+			}
+
+			records, err := cmdrunner.ReadAuditLog(getAuditLogFileOrFail(cmd), filter)
+			if err != nil {
+				log.Fatal().
+					Ctx(cmd.Context()).
+					Err(err).
+					Msg("Failed to read audit log")
+			}
+
+			switch format := getStringFlagOrFail(cmd, "format"); format {
+			case "json":
+				// Same format as the audit log itself, one record per line
+				encoder := json.NewEncoder(os.Stdout)
+				for _, record := range records {
+					_ = encoder.Encode(record)
+				}
+			case "text":
+				writeAuditRecords(records)
+			default:
+				log.Fatal().
+					Ctx(cmd.Context()).
+					Msgf("Unsupported format %q, supported formats are \"text\" and \"json\"", format)
+			}
+		},
+	}
+
+	_ = cmd.Flags().String("tool", "", "Only show the runs of this tool, e.g. npm or uvx")
+	_ = cmd.Flags().String("since", "", "Only show the runs since this date or time, e.g. 2026-01-31, or for this long, e.g. 24h")
+	_ = cmd.Flags().String("until", "", "Only show the runs until this date, inclusive, or time")
+	_ = cmd.Flags().String("format", "text", "Output format, one of \"text\" or \"json\" (one record per line)")
+	return cmd
+}
+
+// getTimeFlagOrFail parses a date, e.g. 2026-01-31, an RFC 3339 time or a duration before now, e.g. 24h.
+// With endOfDay, a date means the end of that day instead of its start.
+func getTimeFlagOrFail(cmd *cobra.Command, name string, endOfDay bool) time.Time {
+	value := getStringFlagOrFail(cmd, name)
+	timestamp, err := parseTime(value, endOfDay, time.Now())
+	if err != nil {
+		log.Fatal().
+			Ctx(cmd.Context()).
+			Err(err).
+			Str("flagName", name).
+			Msg("Invalid time")
+	}
+	return timestamp
+}
+
+// parseTime parses the value of a time flag, durations are relative to now. An empty value is the zero time.
+func parseTime(value string, endOfDay bool, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if date, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if endOfDay {
+			return date.AddDate(0, 0, 1), nil
+		}
+		return date, nil
+	}
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+		return now.Add(-duration), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a date like 2026-01-31, an RFC 3339 time "+
+		"or a duration like 24h", value)
+}
+
+func writeAuditRecords(records []cmdrunner.AuditRecord) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "TIME\tTOOL\tSTATUS\tEXIT\tDURATION\tNETWORK\tACCESS\tDIRECTORY\tCOMMAND")
+	for _, record := range records {
+		duration := time.Duration(record.DurationSeconds * float64(time.Second)).Round(time.Millisecond)
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", record.Time.Local().Format(time.DateTime),
+			record.CmdType, record.Status, describeExitCode(record), duration, record.Network, describeWorkingDirAccess(record),
+			record.WorkingDir, strings.Join(record.Command, " "))
+	}
+	_ = writer.Flush()
+}
+
+// describeExitCode returns the exit code of the run, "-" if the container did not exit
+func describeExitCode(record cmdrunner.AuditRecord) string {
+	if record.Status != cmdrunner.AuditStatusExited {
+		return "-"
+	}
+	return strconv.Itoa(record.ExitCode)
+}
+
+// describeWorkingDirAccess returns how the working directory was mounted: "RW", "RO" or "none"
+func describeWorkingDirAccess(record cmdrunner.AuditRecord) string {
+	for _, mount := range record.Mounts {
+		if mount.Type != cmdrunner.MountTypeBind || mount.Source != record.WorkingDir {
+			continue
+		}
+		if mount.ReadOnly {
+			return "RO"
+		}
+		return "RW"
+	}
+	return "none"
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value    string
+		endOfDay bool
+		want     time.Time
+		wantErr  bool
+	}{
+		{value: "", want: time.Time{}},
+		{value: "", endOfDay: true, want: time.Time{}},
+		{value: "2026-01-31", want: time.Date(2026, 1, 31, 0, 0, 0, 0, time.Local)},
+		{value: "2026-01-31", endOfDay: true, want: time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local)},
+		{value: "2026-12-31", endOfDay: true, want: time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)},
+		{value: "2026-01-31T10:30:00Z", want: time.Date(2026, 1, 31, 10, 30, 0, 0, time.UTC)},
+		{value: "2026-01-31T10:30:00Z", endOfDay: true, want: time.Date(2026, 1, 31, 10, 30, 0, 0, time.UTC)},
+		{value: "2026-01-31T10:30:00+02:00", want: time.Date(2026, 1, 31, 8, 30, 0, 0, time.UTC)},
+		{value: "24h", want: now.Add(-24 * time.Hour)},
+		{value: "1h30m", endOfDay: true, want: now.Add(-90 * time.Minute)},
+		{value: "0s", wantErr: true},
+		{value: "-24h", wantErr: true},
+		{value: "yesterday", wantErr: true},
+		{value: "2026-02-30", wantErr: true},
+		{value: "31/01/2026", wantErr: true},
+		{value: "2026-01-31 10:30", wantErr: true},
+		{value: "7d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTime(tt.value, tt.endOfDay, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTime(%q, %t) error = %v, want error %t", tt.value, tt.endOfDay, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseTime(%q, %t) = %s, want %s", tt.value, tt.endOfDay, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTimeFlagOrFail(t *testing.T) {
+	cmd := logCmd()
+	if err := cmd.Flags().Set("since", "2026-01-31"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := getTimeFlagOrFail(cmd, "since", false), time.Date(2026, 1, 31, 0, 0, 0, 0, time.Local); !got.Equal(want) {
+		t.Errorf("getTimeFlagOrFail(since) = %s, want %s", got, want)
+	}
+	if got := getTimeFlagOrFail(cmd, "until", true); !got.IsZero() {
+		t.Errorf("getTimeFlagOrFail(until) = %s, want the zero time", got)
+	}
+}
@@ -18,7 +18,9 @@ func main() {
 
 	log.Trace().
 		Msg("This is the 'asb' command.")
-	if err := getRootCmd().Execute(); err != nil {
+	rootCmd := getRootCmd()
+	rootCmd.SetArgs(separateToolArgs(rootCmd, os.Args[1:]))
+	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -3,6 +3,8 @@ package main
 import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+
+	"github.com/ashishb/asb/src/asb/pkg/cmdrunner"
 )
 
 const _description = "asb is CLI tool for running tools inside Sandbox\n" +
@@ -25,35 +27,52 @@ func getRootCmd() *cobra.Command {
 
 	_ = rootCmd.PersistentFlags().StringP("directory", "d", getCwdOrFail(), "Working directory for this command")
 	_ = rootCmd.PersistentFlags().BoolP("no-network", "n", false, "Disable network access inside the sandbox")
+	_ = rootCmd.PersistentFlags().String("network", string(cmdrunner.NetworkHost),
+		"Network access inside the sandbox, one of \"host\", \"none\", \"bridge\" or \"proxy\" (package registries only)")
+	_ = rootCmd.PersistentFlags().StringArrayP("publish", "p", nil,
+		"Publish a container port on the host as [hostIP:][hostPort:]containerPort, implies --network=bridge")
 	_ = rootCmd.PersistentFlags().BoolP("read-only", "r", false, "Load working directory and referenced directories as read-only")
 	_ = rootCmd.PersistentFlags().BoolP("read-write", "w", true, "Load working directory and referenced directories as read-only")
+	_ = rootCmd.PersistentFlags().String("memory", "", "Memory limit of the sandbox, e.g. 512m or 2g")
+	_ = rootCmd.PersistentFlags().Float64("cpus", 0, "Number of CPUs available to the sandbox, e.g. 1.5")
+	_ = rootCmd.PersistentFlags().Int64("pids-limit", 0, "Maximum number of processes inside the sandbox")
+	_ = rootCmd.PersistentFlags().Duration("timeout", 0, "Kill the sandbox after this duration, e.g. 30m")
 	_ = rootCmd.PersistentFlags().BoolP("no-disk-access", "x", false, "Disable disk access inside the sandbox")
+	_ = rootCmd.PersistentFlags().Bool("writable-paths-only", false,
+		"Load working directory as read-only, except for the tool's writable paths like node_modules")
+	_ = rootCmd.PersistentFlags().StringArray("writable-path", nil,
+		"Path of the working directory to make writable, relative to it, implies --writable-paths-only")
 	_ = rootCmd.PersistentFlags().BoolP("load-env", "e", true, "Load .env file from working directory")
+	_ = rootCmd.PersistentFlags().Bool("read-only-rootfs", false,
+		"Mount the sandbox's root filesystem as read-only, with a tmpfs for /tmp")
+	_ = rootCmd.PersistentFlags().Bool("allow-git-write", false,
+		"Let the sandbox write to the .git directory of the working directory, which is read-only by default")
+	_ = rootCmd.PersistentFlags().Bool("insecure", false,
+		"Disable the hardened security profile (dropped capabilities, no-new-privileges and seccomp profile)")
+	_ = rootCmd.PersistentFlags().String("backend", string(cmdrunner.BackendAuto),
+		"Container backend, one of \"auto\", \"docker\" or \"podman\"")
+	_ = rootCmd.PersistentFlags().String("cache-scope", string(cmdrunner.CacheScopeGlobal),
+		"Which projects share the cache volumes, one of \"global\", \"project\" or \"zone\"")
+	_ = rootCmd.PersistentFlags().String("cache-zone", "",
+		"Name of the trust zone whose cache volumes are used, implies --cache-scope=zone")
+	_ = rootCmd.PersistentFlags().Bool("read-only-cache", false,
+		"Give the sandbox throwaway copies of the cache volumes, so that it cannot modify the cache")
+	_ = rootCmd.PersistentFlags().String("tool-version", "",
+		"Version of the tool's toolchain, e.g. 20 or \">=3.10\", instead of the one requested by the project files")
+	_ = rootCmd.PersistentFlags().Bool("dry-run", false,
+		"Print the sandbox that would be created instead of running the command, like \"asb explain\"")
+	_ = rootCmd.PersistentFlags().Bool("run-as-root", false, "Run the sandboxed process as root instead of the current user")
 
-	rootCmd.AddCommand(versionCmd())
+	toolRegistry := loadToolRegistryOrFail()
+	cmdrunner.SetToolRegistry(toolRegistry)
 
-	// Python related
-	if false { // Disabled for now
-		rootCmd.AddCommand(pipCmd())
-		rootCmd.AddCommand(pipExecCmd())
-	}
-	rootCmd.AddCommand(uvCmd())
-	rootCmd.AddCommand(uvxCmd())
-	rootCmd.AddCommand(poetryCmd())
-
-	// Rust related
-	rootCmd.AddCommand(cargoCmd())
-	rootCmd.AddCommand(cargoExecCmd())
-
-	// Ruby related
-	rootCmd.AddCommand(gemCmd())
-	rootCmd.AddCommand(gemExecCmd())
-
-	// Javascript related
-	rootCmd.AddCommand(bunCmd())
-	rootCmd.AddCommand(npmCmd())
-	rootCmd.AddCommand(npxCmd())
-	rootCmd.AddCommand(yarnCmd())
+	rootCmd.AddCommand(versionCmd())
+	rootCmd.AddCommand(explainCmd(toolRegistry))
+	rootCmd.AddCommand(cacheCmd())
+	rootCmd.AddCommand(lockCmd())
+	rootCmd.AddCommand(doctorCmd())
+	rootCmd.AddCommand(logCmd())
+	addToolCmds(rootCmd, toolRegistry)
 
 	return rootCmd
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ashishb/asb/src/asb/pkg/cmdrunner"
+)
+
+func runCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   string(cmdrunner.CmdTypeImage),
+		Short: "Run a command inside the sandbox using any container image",
+		Example: "  asb run --image hadolint/hadolint -- hadolint Dockerfile\n" +
+			"  asb -n run --image koalaman/shellcheck-alpine --entrypoint shellcheck -- scripts/build.sh",
+		Args: func(cmd *cobra.Command, args []string) error {
+			// Everything after "--" goes to the image as is, flags included
+			if len(args) > 0 && cmd.ArgsLenAtDash() != 0 {
+				return errors.New("the command to run must follow \"--\"")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			entrypoint := make([]string, 0)
+			if value := getStringFlagOrFail(cmd, "entrypoint"); value != "" {
+				entrypoint = append(entrypoint, value)
+			}
+			runTool(cmd, cmdrunner.CmdTypeImage, args,
+				cmdrunner.SetImage(getStringFlagOrFail(cmd, "image")),
+				cmdrunner.SetEntrypoint(entrypoint))
+		},
+	}
+
+	_ = cmd.Flags().String("image", "", "Container image to run the command in, e.g. hadolint/hadolint")
+	_ = cmd.Flags().String("entrypoint", "", "Override the image's entrypoint")
+	_ = cmd.MarkFlagRequired("image")
+	return cmd
+}
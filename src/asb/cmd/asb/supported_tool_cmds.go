@@ -1,111 +1,66 @@
 package main
 
 import (
+	"slices"
+
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
-	"github.com/ashishb/asb/src/asb/internal/cmdrunner"
+	"github.com/ashishb/asb/src/asb/internal/config"
+	"github.com/ashishb/asb/src/asb/pkg/cmdrunner"
+	"github.com/ashishb/asb/src/asb/pkg/registry"
 )
 
-func cargoCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "cargo",
-		Short: "Run a cargo command",
-	}
-	return createCmd(cmd, cmdrunner.CmdTypeRustCargo)
-}
+// Commands that cobra adds on its own
+var _cobraCmdNames = []string{"help", "completion"}
 
-func cargoExecCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "cargo-exec",
-		Short: "Run a Rust-based binary package already installed inside sandbox",
+// loadToolRegistryOrFail returns the tools shipped with asb, extended with the ones from the user config
+func loadToolRegistryOrFail() *registry.Registry {
+	toolRegistry := registry.Default()
+	userConfig, err := config.LoadUser()
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Failed to load user config")
 	}
-	return createCmd(cmd, cmdrunner.CmdTypeRustCargoExec)
-}
 
-func pipCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "pip",
-		Short: "Install Python packages using pip",
+	if userConfig.ToolRegistry == nil {
+		return toolRegistry
 	}
-	return createCmd(cmd, cmdrunner.CmdTypePythonPip)
-}
 
-func pipExecCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "pip-exec",
-		Short: "Run a Python-based package already installed inside sandbox",
+	toolRegistry, err = toolRegistry.Extend(userConfig.ToolRegistry)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str("configFile", userConfig.Path()).
+			Msg("Invalid tool-registry in user config")
 	}
-	return createCmd(cmd, cmdrunner.CmdTypePythonPipExec)
+	return toolRegistry
 }
 
-func uvCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "uv",
-		Short: "Run a uv command",
-	}
-	return createCmd(cmd, cmdrunner.CmdTypePythonUv)
-}
+// addToolCmds adds "asb run" and a command for each tool in the registry
+func addToolCmds(parent *cobra.Command, toolRegistry *registry.Registry) {
+	parent.AddCommand(runCmd())
+	for _, tool := range toolRegistry.Tools {
+		if tool.Hidden {
+			continue
+		}
 
-func uvxCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "uvx",
-		Short: "Run a Python-based package already installed inside sandbox using uvx",
-	}
-	return createCmd(cmd, cmdrunner.CmdTypePythonUvx)
-}
-
-func poetryCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "poetry",
-		Short: "Run a poetry command",
-	}
-	return createCmd(cmd, cmdrunner.CmdTypePythonPoetry)
-}
-
-func gemCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "gem",
-		Short: "Run a Ruby gem-based CLI tool",
-	}
-	return createCmd(cmd, cmdrunner.CmdTypeRubyGem)
-}
-
-func gemExecCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "gem-exec",
-		Short: "Run a gem already installed inside sandbox",
-	}
-	return createCmd(cmd, cmdrunner.CmdTypeRubyGemExec)
-}
-
-func bunCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "bun",
-		Short: "Run a bun command",
-	}
-	return createCmd(cmd, cmdrunner.CmdTypeBun)
-}
-
-func npmCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "npm",
-		Short: "Run an npm command",
-	}
-	return createCmd(cmd, cmdrunner.CmdTypeNpm)
-}
-
-func npxCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "npx",
-		Short: "Run an npx command",
+		isBuiltIn := func(cmd *cobra.Command) bool { return cmd.Name() == tool.Command }
+		if slices.Contains(_cobraCmdNames, tool.Command) || slices.ContainsFunc(parent.Commands(), isBuiltIn) {
+			log.Fatal().
+				Str("tool", tool.Name).
+				Str("command", tool.Command).
+				Msg("Tool command clashes with a built-in command")
+		}
+		parent.AddCommand(toolCmd(tool))
 	}
-	return createCmd(cmd, cmdrunner.CmdTypeNpx)
 }
 
-func yarnCmd() *cobra.Command {
+func toolCmd(tool registry.Tool) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "yarn",
-		Short: "Run a yarn command",
+		Use:   tool.Command,
+		Short: tool.Description,
 	}
-	return createCmd(cmd, cmdrunner.CmdTypeYarn)
+	return createCmd(cmd, cmdrunner.CmdType(tool.Name))
 }
@@ -0,0 +1,408 @@
+package config
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ashishb/asb/src/asb/pkg/registry"
+)
+
+const (
+	// ProjectFileName is the name of the per-project config file
+	ProjectFileName = ".asb.yaml"
+	// UserFileName is the name of the user-level config file inside the user config directory,
+	// e.g. ~/.config/asb/config.yaml
+	UserFileName = "config.yaml"
+)
+
+// Mount is an extra bind mount to add to the sandbox
+type Mount struct {
+	Source   string `yaml:"source"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"read-only"`
+}
+
+// Settings are the policy knobs that can be set either globally or per tool.
+// Pointers are used so that "not set" can be told apart from "set to false".
+type Settings struct {
+	NoNetwork     *bool             `yaml:"no-network"`
+	ReadOnly      *bool             `yaml:"read-only"`
+	NoDiskAccess  *bool             `yaml:"no-disk-access"`
+	LoadEnv       *bool             `yaml:"load-env"`
+	RunAsRoot     *bool             `yaml:"run-as-root"`
+	Insecure      *bool             `yaml:"insecure"`
+	ReadOnlyFS    *bool             `yaml:"read-only-rootfs"`
+	AllowGitWrite *bool             `yaml:"allow-git-write"`
+	Network       *string           `yaml:"network"`
+	Backend       *string           `yaml:"backend"`
+	CacheScope    *string           `yaml:"cache-scope"`
+	CacheZone     *string           `yaml:"cache-zone"`
+	ReadOnlyCache *bool             `yaml:"read-only-cache"`
+	ToolVersion   *string           `yaml:"tool-version"`
+	Publish       []string          `yaml:"publish"`
+	Memory        *string           `yaml:"memory"`
+	CPUs          *float64          `yaml:"cpus"`
+	PidsLimit     *int64            `yaml:"pids-limit"`
+	Timeout       *string           `yaml:"timeout"`
+	Mounts        []Mount           `yaml:"mounts"`
+	Env           map[string]string `yaml:"env"`
+
+	// Paths never to mount, in addition to the built-in ones like ~/.ssh
+	SensitivePaths []string `yaml:"sensitive-paths"`
+	// Paths of the working directory mounted as read-only, in addition to .git, relative to it
+	ReadOnlyPaths []string `yaml:"read-only-paths"`
+	// Mount the working directory as read-only, except for the writable paths, e.g. node_modules
+	WritablePathsOnly *bool `yaml:"writable-paths-only"`
+	// Paths of the working directory that are read-write with writable-paths-only, in addition to the tool's ones
+	WritablePaths []string `yaml:"writable-paths"`
+
+	// Domains reachable with the "proxy" network, in addition to the tool's package registries
+	AllowedDomains []string `yaml:"allowed-domains"`
+}
+
+// Config is the parsed content of a config file
+type Config struct {
+	Settings `yaml:",inline"`
+
+	// Per-tool overrides keyed by command type, e.g. "npm" or "python_uvx"
+	Tools map[string]Settings `yaml:"tools"`
+
+	// Tools added to the ones shipped with asb, which they cannot replace, only allowed in the user config
+	// as it decides which images and commands run
+	ToolRegistry *registry.Registry `yaml:"tool-registry"`
+
+	// File that a record of every run is appended to, only allowed in the user config
+	// as a project must not be able to hide its runs or write to other files
+	AuditLog string `yaml:"audit-log"`
+
+	// Built-in sensitive paths that can be mounted, e.g. ~/.docker, only allowed in the user config
+	// as a project must not be able to expose them
+	AllowSensitivePaths []string `yaml:"allow-sensitive-paths"`
+
+	// Project directories whose config file can loosen the sandbox, e.g. set insecure or network "host",
+	// only allowed in the user config as a project must not be able to trust itself
+	TrustedProjects []string `yaml:"trusted-projects"`
+
+	path string // Path of the file this config was loaded from, empty if none
+}
+
+// Path returns the path of the file this config was loaded from
+func (c *Config) Path() string {
+	return c.path
+}
+
+// ForTool returns the settings that apply to the given command type.
+// Tool specific values override the global ones, mounts are concatenated and env is merged.
+func (c *Config) ForTool(cmdType string) Settings {
+	return mergeSettings(c.Settings, c.Tools[cmdType])
+}
+
+// Load loads the user config and the project config for the given working directory
+// and layers them, the project config overrides the user config.
+// The settings that loosen the sandbox are ignored in a project config that the user config does not trust.
+func Load(workingDir string) (*Config, error) {
+	userConfig, err := LoadUser()
+	if err != nil {
+		return nil, err
+	}
+
+	projectConfig, err := LoadProject(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !userConfig.isTrusted(projectConfig) {
+		projectConfig = projectConfig.withoutLooseningSettings()
+	}
+	return mergeConfigs(userConfig, projectConfig), nil
+}
+
+// LoadUser loads the user-level config file, an empty config is returned if it does not exist
+func LoadUser() (*Config, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Failed to get user config directory, skipping user config")
+		return &Config{}, nil
+	}
+
+	configFile := filepath.Join(configDir, "asb", UserFileName)
+	if _, err = os.Stat(configFile); errors.Is(err, os.ErrNotExist) {
+		log.Debug().
+			Str("configFile", configFile).
+			Msg("No user config file found")
+		return &Config{}, nil
+	}
+	return loadFile(configFile)
+}
+
+// LoadProject finds and loads the project config file for the given working directory.
+// An empty config is returned if no config file exists.
+func LoadProject(workingDir string) (*Config, error) {
+	configFile, err := findProjectFile(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if configFile == "" {
+		log.Debug().
+			Str("workingDir", workingDir).
+			Msg("No project config file found")
+		return &Config{}, nil
+	}
+
+	cfg, err := loadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ToolRegistry != nil {
+		return nil, fmt.Errorf("tool-registry in project config file %s is not allowed, move it to the user config file",
+			configFile)
+	}
+	if cfg.AuditLog != "" {
+		return nil, fmt.Errorf("audit-log in project config file %s is not allowed, move it to the user config file",
+			configFile)
+	}
+	if len(cfg.AllowSensitivePaths) > 0 {
+		return nil, fmt.Errorf("allow-sensitive-paths in project config file %s is not allowed, "+
+			"move it to the user config file", configFile)
+	}
+	if len(cfg.TrustedProjects) > 0 {
+		return nil, fmt.Errorf("trusted-projects in project config file %s is not allowed, "+
+			"move it to the user config file", configFile)
+	}
+
+	// A project can only mount its own files, else a sandboxed tool could add a mount of e.g. ~/.bashrc
+	// to the project config and get it on the next run
+	projectDir := filepath.Dir(configFile)
+	if err = checkMountsInsideDir(projectDir, cfg.Mounts); err != nil {
+		return nil, fmt.Errorf("invalid mounts in project config file %s, move them to the user config file: %w",
+			configFile, err)
+	}
+	for name, toolSettings := range cfg.Tools {
+		if err = checkMountsInsideDir(projectDir, toolSettings.Mounts); err != nil {
+			return nil, fmt.Errorf("invalid mounts for tool %q in project config file %s, "+
+				"move them to the user config file: %w", name, configFile, err)
+		}
+	}
+	return cfg, nil
+}
+
+// findProjectFile looks for the project config file in workingDir and its parents
+func findProjectFile(workingDir string) (string, error) {
+	return findFile(workingDir, ProjectFileName)
+}
+
+// findFile looks for the file in workingDir and its parents.
+// The search stops at the repository root (the directory containing ".git") or at
+// the filesystem root, whichever comes first.
+func findFile(workingDir string, fileName string) (string, error) {
+	dir, err := filepath.Abs(workingDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path of %s: %w", workingDir, err)
+	}
+
+	for {
+		candidate := filepath.Join(dir, fileName)
+		if fileInfo, err := os.Stat(candidate); err == nil && !fileInfo.IsDir() {
+			return candidate, nil
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			// Reached the repository root
+			return "", nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// findProjectDir returns the directory of the project config file, else the repository root,
+// else workingDir itself
+func findProjectDir(workingDir string) (string, error) {
+	projectFile, err := findProjectFile(workingDir)
+	if err != nil || projectFile != "" {
+		return filepath.Dir(projectFile), err
+	}
+
+	workingDir, err = filepath.Abs(workingDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path of %s: %w", workingDir, err)
+	}
+
+	for dir := workingDir; filepath.Dir(dir) != dir; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, nil
+		}
+	}
+	return workingDir, nil
+}
+
+func loadFile(configFile string) (*Config, error) {
+	file, err := os.Open(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file %s: %w", configFile, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var cfg Config
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", configFile, err)
+	}
+
+	cfg.path = configFile
+	baseDir := filepath.Dir(configFile)
+	cfg.Mounts, err = resolveMounts(baseDir, cfg.Mounts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mounts in config file %s: %w", configFile, err)
+	}
+
+	if cfg.AuditLog != "" {
+		if cfg.AuditLog, err = expandPath(baseDir, cfg.AuditLog); err != nil {
+			return nil, fmt.Errorf("invalid audit-log in config file %s: %w", configFile, err)
+		}
+	}
+
+	for i, project := range cfg.TrustedProjects {
+		if cfg.TrustedProjects[i], err = expandPath(baseDir, project); err != nil {
+			return nil, fmt.Errorf("invalid trusted-projects in config file %s: %w", configFile, err)
+		}
+	}
+
+	for name, toolSettings := range cfg.Tools {
+		toolSettings.Mounts, err = resolveMounts(baseDir, toolSettings.Mounts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mounts for tool %q in config file %s: %w", name, configFile, err)
+		}
+		cfg.Tools[name] = toolSettings
+	}
+
+	log.Debug().
+		Str("configFile", configFile).
+		Msg("Loaded config file")
+	return &cfg, nil
+}
+
+// resolveMounts makes mount sources absolute (relative to baseDir) and defaults the target to the source
+func resolveMounts(baseDir string, mounts []Mount) ([]Mount, error) {
+	result := make([]Mount, 0, len(mounts))
+	for _, mount := range mounts {
+		if mount.Source == "" {
+			return nil, errors.New("mount source cannot be empty")
+		}
+
+		source, err := expandPath(baseDir, mount.Source)
+		if err != nil {
+			return nil, err
+		}
+
+		mount.Source = source
+		if mount.Target == "" {
+			mount.Target = source
+		}
+
+		if !filepath.IsAbs(mount.Target) {
+			return nil, fmt.Errorf("mount target %q must be an absolute path", mount.Target)
+		}
+		result = append(result, mount)
+	}
+	return result, nil
+}
+
+// expandPath expands a leading "~" to the home directory and makes the path absolute
+func expandPath(baseDir string, path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get user home directory: %w", err)
+		}
+		path = filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	return filepath.Clean(path), nil
+}
+
+// mergeConfigs layers override on top of base
+func mergeConfigs(base *Config, override *Config) *Config {
+	result := &Config{
+		Settings: mergeSettings(base.Settings, override.Settings),
+		Tools:    make(map[string]Settings, len(base.Tools)+len(override.Tools)),
+		path:     cmp.Or(override.path, base.path),
+	}
+	maps.Copy(result.Tools, base.Tools)
+	for name, toolSettings := range override.Tools {
+		result.Tools[name] = mergeSettings(result.Tools[name], toolSettings)
+	}
+	return result
+}
+
+// mergeSettings layers override on top of base.
+// Set values in override win, mounts are concatenated and env is merged.
+func mergeSettings(base Settings, override Settings) Settings {
+	result := Settings{
+		NoNetwork:     firstNonNil(override.NoNetwork, base.NoNetwork),
+		ReadOnly:      firstNonNil(override.ReadOnly, base.ReadOnly),
+		NoDiskAccess:  firstNonNil(override.NoDiskAccess, base.NoDiskAccess),
+		LoadEnv:       firstNonNil(override.LoadEnv, base.LoadEnv),
+		RunAsRoot:     firstNonNil(override.RunAsRoot, base.RunAsRoot),
+		Insecure:      firstNonNil(override.Insecure, base.Insecure),
+		ReadOnlyFS:    firstNonNil(override.ReadOnlyFS, base.ReadOnlyFS),
+		AllowGitWrite: firstNonNil(override.AllowGitWrite, base.AllowGitWrite),
+		Network:       firstNonNil(override.Network, base.Network),
+		Backend:       firstNonNil(override.Backend, base.Backend),
+		CacheScope:    firstNonNil(override.CacheScope, base.CacheScope),
+		CacheZone:     firstNonNil(override.CacheZone, base.CacheZone),
+		ReadOnlyCache: firstNonNil(override.ReadOnlyCache, base.ReadOnlyCache),
+		ToolVersion:   firstNonNil(override.ToolVersion, base.ToolVersion),
+		Publish:       slices.Concat(base.Publish, override.Publish),
+		Memory:        firstNonNil(override.Memory, base.Memory),
+		CPUs:          firstNonNil(override.CPUs, base.CPUs),
+		PidsLimit:     firstNonNil(override.PidsLimit, base.PidsLimit),
+		Timeout:       firstNonNil(override.Timeout, base.Timeout),
+		Mounts:        slices.Concat(base.Mounts, override.Mounts),
+
+		SensitivePaths: slices.Concat(base.SensitivePaths, override.SensitivePaths),
+		ReadOnlyPaths:  slices.Concat(base.ReadOnlyPaths, override.ReadOnlyPaths),
+		WritablePaths:  slices.Concat(base.WritablePaths, override.WritablePaths),
+		AllowedDomains: slices.Concat(base.AllowedDomains, override.AllowedDomains),
+
+		WritablePathsOnly: firstNonNil(override.WritablePathsOnly, base.WritablePathsOnly),
+	}
+
+	if len(base.Env)+len(override.Env) > 0 {
+		result.Env = make(map[string]string, len(base.Env)+len(override.Env))
+		maps.Copy(result.Env, base.Env)
+		maps.Copy(result.Env, override.Env)
+	}
+	return result
+}
+
+func firstNonNil[T any](values ...*T) *T {
+	for _, value := range values {
+		if value != nil {
+			return value
+		}
+	}
+	return nil
+}
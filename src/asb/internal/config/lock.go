@@ -0,0 +1,92 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// LockFileName is the name of the per-project file that pins the tool images to their digests
+const LockFileName = "asb.lock"
+
+const _lockFileHeader = "# Generated by \"asb lock\", commit it so that everyone runs the same images.\n" +
+	"# Run \"asb lock --update\" to update the images.\n"
+
+// Lock is the parsed content of the lock file
+type Lock struct {
+	// Digest of each image keyed by the image, e.g. "node:25-bookworm": "sha256:..."
+	Images map[string]string `yaml:"images"`
+
+	path string // Path of the lock file, it may not exist yet
+}
+
+// Path returns the path of the lock file
+func (l *Lock) Path() string {
+	return l.path
+}
+
+// LoadLock finds and loads the lock file for the given working directory.
+// If there is none, an empty lock is returned that saves next to the project config file,
+// else in the repository root, else in the working directory.
+func LoadLock(workingDir string) (*Lock, error) {
+	lockFile, err := findFile(workingDir, LockFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if lockFile == "" {
+		projectDir, err := findProjectDir(workingDir)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Debug().
+			Str("workingDir", workingDir).
+			Msg("No lock file found")
+		return &Lock{Images: make(map[string]string), path: filepath.Join(projectDir, LockFileName)}, nil
+	}
+
+	file, err := os.Open(lockFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %s: %w", lockFile, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	lock := Lock{path: lockFile}
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&lock); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", lockFile, err)
+	}
+	if lock.Images == nil {
+		lock.Images = make(map[string]string)
+	}
+
+	log.Debug().
+		Str("lockFile", lockFile).
+		Msg("Loaded lock file")
+	return &lock, nil
+}
+
+// Save writes the lock file
+func (l *Lock) Save() error {
+	var buffer bytes.Buffer
+	buffer.WriteString(_lockFileHeader)
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(l); err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+
+	//nolint:gosec  // The lock file is meant to be committed and read by everyone
+	if err := os.WriteFile(l.path, buffer.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write lock file %s: %w", l.path, err)
+	}
+	return nil
+}
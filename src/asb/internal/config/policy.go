@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyFile is the system-wide policy file, usually managed by an organization's security team.
+// Neither the user nor the project config can loosen the rules set in it.
+const PolicyFile = "/etc/asb/policy.yaml"
+
+// PolicyRules are the hard limits enforced on every sandbox
+type PolicyRules struct {
+	NoNetwork    bool     `yaml:"no-network"`    // Network must be disabled
+	ReadOnly     bool     `yaml:"read-only"`     // Disk access, if any, must be read-only
+	NoLoadEnv    bool     `yaml:"no-load-env"`   // .env file must not be loaded
+	NoInsecure   bool     `yaml:"no-insecure"`   // Hardened security profile cannot be disabled
+	DeniedMounts []string `yaml:"denied-mounts"` // Paths that must never be mounted
+}
+
+// Policy is the parsed content of the policy file
+type Policy struct {
+	PolicyRules `yaml:",inline"`
+
+	// Per-tool rules keyed by command type, these are added on top of the global rules
+	Tools map[string]PolicyRules `yaml:"tools"`
+
+	path string // Path of the file this policy was loaded from, empty if none
+}
+
+// Path returns the path of the file this policy was loaded from
+func (p *Policy) Path() string {
+	return p.path
+}
+
+// ForTool returns the rules that apply to the given command type.
+// A rule enabled either globally or for the tool is enforced.
+func (p *Policy) ForTool(cmdType string) PolicyRules {
+	toolRules := p.Tools[cmdType]
+	return PolicyRules{
+		NoNetwork:    p.NoNetwork || toolRules.NoNetwork,
+		ReadOnly:     p.ReadOnly || toolRules.ReadOnly,
+		NoLoadEnv:    p.NoLoadEnv || toolRules.NoLoadEnv,
+		NoInsecure:   p.NoInsecure || toolRules.NoInsecure,
+		DeniedMounts: slices.Concat(p.DeniedMounts, toolRules.DeniedMounts),
+	}
+}
+
+// LoadPolicy loads the system-wide policy file, an empty policy is returned if it does not exist
+func LoadPolicy() (*Policy, error) {
+	file, err := os.Open(PolicyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Debug().
+			Str("policyFile", PolicyFile).
+			Msg("No policy file found")
+		return &Policy{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open policy file %s: %w", PolicyFile, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var policy Policy
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&policy); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", PolicyFile, err)
+	}
+
+	policy.path = PolicyFile
+	policy.DeniedMounts, err = expandPaths(policy.DeniedMounts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid denied-mounts in policy file %s: %w", PolicyFile, err)
+	}
+
+	for name, toolRules := range policy.Tools {
+		toolRules.DeniedMounts, err = expandPaths(toolRules.DeniedMounts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid denied-mounts for tool %q in policy file %s: %w",
+				name, PolicyFile, err)
+		}
+		policy.Tools[name] = toolRules
+	}
+
+	log.Debug().
+		Str("policyFile", PolicyFile).
+		Msg("Loaded policy file")
+	return &policy, nil
+}
+
+func expandPaths(paths []string) ([]string, error) {
+	result := make([]string, 0, len(paths))
+	for _, path := range paths {
+		expanded, err := expandPath(string(filepath.Separator), path)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, expanded)
+	}
+	return result, nil
+}
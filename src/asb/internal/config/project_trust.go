@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Values of the settings that an untrusted project config can set, as they tighten the sandbox
+const (
+	_networkNone       = "none"
+	_cacheScopeProject = "project"
+)
+
+// isTrusted returns true if the user config trusts the project config, i.e. its directory is in trusted-projects
+func (c *Config) isTrusted(projectConfig *Config) bool {
+	if projectConfig.path == "" {
+		return false
+	}
+	return slices.Contains(c.TrustedProjects, filepath.Dir(projectConfig.path))
+}
+
+// withoutLooseningSettings returns the project config with only the settings that tighten the sandbox,
+// e.g. read-only: true, while e.g. load-env: true is dropped.
+// A sandboxed tool can write the project config of a read-write working directory, so the other settings
+// need the project to be trusted in the user config, or to be passed as flags.
+func (c *Config) withoutLooseningSettings() *Config {
+	result := *c
+	result.Settings = keepTighteningSettings(c.path, "", c.Settings)
+	result.Tools = make(map[string]Settings, len(c.Tools))
+	for name, toolSettings := range c.Tools {
+		result.Tools[name] = keepTighteningSettings(c.path, "tools."+name+".", toolSettings)
+	}
+	return &result
+}
+
+// keepTighteningSettings is an allowlist, so that a setting added later is dropped until it is added here
+func keepTighteningSettings(configFile string, keyPrefix string, settings Settings) Settings {
+	ignore := func(key string) {
+		log.Warn().
+			Str("configFile", configFile).
+			Str("key", keyPrefix+key).
+			Msg("Ignoring the setting of the untrusted project config, as it can loosen the sandbox. " +
+				"Pass it as a flag, or add the project to trusted-projects in the user config")
+	}
+
+	// Settings that cannot loosen the sandbox whatever their value, as they only add restrictions
+	// or only change what runs inside it
+	result := Settings{
+		ToolVersion:    settings.ToolVersion,
+		Env:            settings.Env,
+		SensitivePaths: settings.SensitivePaths,
+		ReadOnlyPaths:  settings.ReadOnlyPaths,
+	}
+
+	// Settings that are only kept with the value that tightens the sandbox
+	result.NoNetwork = keepValue(settings.NoNetwork, true, "no-network", ignore)
+	result.ReadOnly = keepValue(settings.ReadOnly, true, "read-only", ignore)
+	result.NoDiskAccess = keepValue(settings.NoDiskAccess, true, "no-disk-access", ignore)
+	result.LoadEnv = keepValue(settings.LoadEnv, false, "load-env", ignore)
+	result.RunAsRoot = keepValue(settings.RunAsRoot, false, "run-as-root", ignore)
+	result.Insecure = keepValue(settings.Insecure, false, "insecure", ignore)
+	result.ReadOnlyFS = keepValue(settings.ReadOnlyFS, true, "read-only-rootfs", ignore)
+	result.AllowGitWrite = keepValue(settings.AllowGitWrite, false, "allow-git-write", ignore)
+	result.Network = keepValue(settings.Network, _networkNone, "network", ignore)
+	result.CacheScope = keepValue(settings.CacheScope, _cacheScopeProject, "cache-scope", ignore)
+	result.ReadOnlyCache = keepValue(settings.ReadOnlyCache, true, "read-only-cache", ignore)
+	result.WritablePathsOnly = keepValue(settings.WritablePathsOnly, true, "writable-paths-only", ignore)
+
+	for _, mount := range settings.Mounts {
+		if !mount.ReadOnly {
+			ignore("mounts")
+			continue
+		}
+		result.Mounts = append(result.Mounts, mount)
+	}
+
+	// Settings that can loosen the sandbox whatever their value, e.g. a higher memory limit than
+	// the user config's one or the tool's default
+	dropped := []struct {
+		key string
+		set bool
+	}{
+		{key: "backend", set: settings.Backend != nil},
+		{key: "cache-zone", set: settings.CacheZone != nil},
+		{key: "publish", set: len(settings.Publish) > 0},
+		{key: "memory", set: settings.Memory != nil},
+		{key: "cpus", set: settings.CPUs != nil},
+		{key: "pids-limit", set: settings.PidsLimit != nil},
+		{key: "timeout", set: settings.Timeout != nil},
+		{key: "writable-paths", set: len(settings.WritablePaths) > 0},
+		{key: "allowed-domains", set: len(settings.AllowedDomains) > 0},
+	}
+	for _, setting := range dropped {
+		if setting.set {
+			ignore(setting.key)
+		}
+	}
+	return result
+}
+
+// keepValue returns value if it is not set or is the tightening one, else nil
+func keepValue[T comparable](value *T, tightening T, key string, ignore func(key string)) *T {
+	if value == nil || *value == tightening {
+		return value
+	}
+	ignore(key)
+	return nil
+}
+
+// checkMountsInsideDir returns an error if a mount source, or the path it resolves to, is outside dir
+func checkMountsInsideDir(dir string, mounts []Mount) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		realDir = dir
+	}
+
+	for _, mount := range mounts {
+		if !isInsideDir(dir, mount.Source) {
+			return fmt.Errorf("mount source %s is outside the project directory %s", mount.Source, dir)
+		}
+		// E.g. a symlink inside the project pointing to the home directory
+		if realSource, err := filepath.EvalSymlinks(mount.Source); err == nil && !isInsideDir(realDir, realSource) {
+			return fmt.Errorf("mount source %s resolves to %s, outside the project directory %s",
+				mount.Source, realSource, dir)
+		}
+	}
+	return nil
+}
+
+// isInsideDir returns true if path is dir or is inside it
+func isInsideDir(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
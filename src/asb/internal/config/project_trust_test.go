@@ -0,0 +1,128 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeepTighteningSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		want     Settings
+	}{
+		// Loosening settings are dropped
+		{name: "no-network false", settings: Settings{NoNetwork: ptr(false)}},
+		{name: "read-only false", settings: Settings{ReadOnly: ptr(false)}},
+		{name: "no-disk-access false", settings: Settings{NoDiskAccess: ptr(false)}},
+		{name: "load-env true", settings: Settings{LoadEnv: ptr(true)}},
+		{name: "run-as-root true", settings: Settings{RunAsRoot: ptr(true)}},
+		{name: "insecure true", settings: Settings{Insecure: ptr(true)}},
+		{name: "read-only-rootfs false", settings: Settings{ReadOnlyFS: ptr(false)}},
+		{name: "allow-git-write true", settings: Settings{AllowGitWrite: ptr(true)}},
+		{name: "network host", settings: Settings{Network: ptr("host")}},
+		{name: "network bridge", settings: Settings{Network: ptr("bridge")}},
+		{name: "network proxy", settings: Settings{Network: ptr("proxy")}},
+		{name: "cache-scope global", settings: Settings{CacheScope: ptr("global")}},
+		{name: "cache-zone", settings: Settings{CacheZone: ptr("work")}},
+		{name: "read-only-cache false", settings: Settings{ReadOnlyCache: ptr(false)}},
+		{name: "writable-paths-only false", settings: Settings{WritablePathsOnly: ptr(false)}},
+		{name: "writable-paths", settings: Settings{WritablePaths: []string{"src"}}},
+		{name: "allowed-domains", settings: Settings{AllowedDomains: []string{"example.com"}}},
+		{name: "backend", settings: Settings{Backend: ptr("docker")}},
+		{name: "publish", settings: Settings{Publish: []string{"3000"}}},
+		{name: "memory", settings: Settings{Memory: ptr("64g")}},
+		{name: "cpus", settings: Settings{CPUs: ptr(64.0)}},
+		{name: "pids-limit", settings: Settings{PidsLimit: ptr(int64(0))}},
+		{name: "timeout", settings: Settings{Timeout: ptr("24h")}},
+		{name: "read-write mount", settings: Settings{Mounts: []Mount{{Source: "/src/a/data", Target: "/data"}}}},
+
+		// Tightening settings are kept
+		{
+			name: "tightening values",
+			settings: Settings{
+				NoNetwork:         ptr(true),
+				ReadOnly:          ptr(true),
+				NoDiskAccess:      ptr(true),
+				LoadEnv:           ptr(false),
+				RunAsRoot:         ptr(false),
+				Insecure:          ptr(false),
+				ReadOnlyFS:        ptr(true),
+				AllowGitWrite:     ptr(false),
+				Network:           ptr("none"),
+				CacheScope:        ptr("project"),
+				ReadOnlyCache:     ptr(true),
+				WritablePathsOnly: ptr(true),
+			},
+			want: Settings{
+				NoNetwork:         ptr(true),
+				ReadOnly:          ptr(true),
+				NoDiskAccess:      ptr(true),
+				LoadEnv:           ptr(false),
+				RunAsRoot:         ptr(false),
+				Insecure:          ptr(false),
+				ReadOnlyFS:        ptr(true),
+				AllowGitWrite:     ptr(false),
+				Network:           ptr("none"),
+				CacheScope:        ptr("project"),
+				ReadOnlyCache:     ptr(true),
+				WritablePathsOnly: ptr(true),
+			},
+		},
+		{
+			name: "settings that only restrict or change what runs",
+			settings: Settings{
+				ToolVersion:    ptr("22"),
+				Env:            map[string]string{"CI": "1"},
+				SensitivePaths: []string{"~/secrets"},
+				ReadOnlyPaths:  []string{"scripts"},
+			},
+			want: Settings{
+				ToolVersion:    ptr("22"),
+				Env:            map[string]string{"CI": "1"},
+				SensitivePaths: []string{"~/secrets"},
+				ReadOnlyPaths:  []string{"scripts"},
+			},
+		},
+		{
+			name: "read-only mount",
+			settings: Settings{Mounts: []Mount{
+				{Source: "/src/a/data", Target: "/data", ReadOnly: true},
+				{Source: "/src/a/out", Target: "/out"},
+			}},
+			want: Settings{Mounts: []Mount{{Source: "/src/a/data", Target: "/data", ReadOnly: true}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keepTighteningSettings(".asb.yaml", "", tt.settings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keepTighteningSettings(%+v) = %+v, want %+v", tt.settings, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithoutLooseningSettingsOfTools(t *testing.T) {
+	projectConfig := &Config{
+		Settings: Settings{LoadEnv: ptr(true)},
+		Tools: map[string]Settings{
+			"node_npm": {NoNetwork: ptr(false), ReadOnly: ptr(true)},
+		},
+	}
+
+	got := projectConfig.withoutLooseningSettings()
+	if !reflect.DeepEqual(got.Settings, Settings{}) {
+		t.Errorf("Settings = %+v, want none", got.Settings)
+	}
+	if want := (Settings{ReadOnly: ptr(true)}); !reflect.DeepEqual(got.Tools["node_npm"], want) {
+		t.Errorf("Settings of node_npm = %+v, want %+v", got.Tools["node_npm"], want)
+	}
+	if projectConfig.LoadEnv == nil {
+		t.Errorf("The project config was modified")
+	}
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,265 @@
+package egressproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	_dialTimeout       = 30 * time.Second
+	_readHeaderTimeout = 30 * time.Second
+)
+
+// Ports that are allowed for domains listed without an explicit port
+var _defaultAllowedPorts = []string{"80", "443"}
+
+// Hop-by-hop headers, these are meant for a single connection and must not be forwarded
+// Ref: https://www.rfc-editor.org/rfc/rfc9110#section-7.6.1
+var _hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Proxy is an HTTP proxy that only lets through requests to an allowlist of domains.
+// HTTPS is supported via the CONNECT method, the proxy never sees the decrypted traffic.
+type Proxy struct {
+	allowedDomains []string
+	dialer         *net.Dialer
+	transport      *http.Transport
+
+	mutex  sync.Mutex
+	server *http.Server
+}
+
+// New creates a proxy that allows the given domains.
+// An entry like "registry.npmjs.org" allows that host only,
+// an entry like "*.crates.io" allows all of its subdomains as well.
+// An entry can optionally pin a port, e.g. "example.com:8443", else ports 80 and 443 are allowed.
+func New(allowedDomains []string) *Proxy {
+	dialer := &net.Dialer{Timeout: _dialTimeout}
+	return &Proxy{
+		allowedDomains: normalizeDomains(allowedDomains),
+		dialer:         dialer,
+		transport: &http.Transport{
+			Proxy:             nil, // Never chain to another proxy
+			DialContext:       dialer.DialContext,
+			ForceAttemptHTTP2: false,
+		},
+	}
+}
+
+// Start starts serving on the given address, e.g. "172.17.0.1:0", and returns the address
+// it is actually listening on
+func (p *Proxy) Start(ctx context.Context, addr string) (string, error) {
+	listenConfig := net.ListenConfig{}
+	listener, err := listenConfig.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           p,
+		ReadHeaderTimeout: _readHeaderTimeout,
+	}
+	p.mutex.Lock()
+	p.server = server
+	p.mutex.Unlock()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().
+				Err(err).
+				Msg("Egress proxy stopped")
+		}
+	}()
+
+	log.Debug().
+		Str("address", listener.Addr().String()).
+		Strs("allowedDomains", p.allowedDomains).
+		Msg("Egress proxy started")
+	return listener.Addr().String(), nil
+}
+
+// Close stops the proxy and closes all its connections
+func (p *Proxy) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.transport.CloseIdleConnections()
+	if p.server == nil {
+		return nil
+	}
+	return p.server.Close()
+}
+
+// IsAllowed returns true if the host (with an optional port) is in the allowlist
+func (p *Proxy) IsAllowed(hostPort string) bool {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host, port = hostPort, ""
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, entry := range p.allowedDomains {
+		domain, entryPort, err := net.SplitHostPort(entry)
+		if err != nil {
+			domain, entryPort = entry, ""
+		}
+
+		if !domainMatches(domain, host) {
+			continue
+		}
+
+		if port == "" || port == entryPort || (entryPort == "" && slices.Contains(_defaultAllowedPorts, port)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hostPort := r.Host
+	if r.Method != http.MethodConnect && r.URL.Host != "" {
+		hostPort = r.URL.Host
+	}
+
+	if !p.IsAllowed(hostPort) {
+		log.Warn().
+			Str("host", hostPort).
+			Str("method", r.Method).
+			Msg("Egress proxy denied request to a host that is not in the allowlist")
+		http.Error(w, fmt.Sprintf("asb: access to %s is not allowed", hostPort), http.StatusForbidden)
+		return
+	}
+
+	log.Debug().
+		Str("host", hostPort).
+		Str("method", r.Method).
+		Msg("Egress proxy allowed request")
+
+	if r.Method == http.MethodConnect {
+		p.handleConnect(w, r)
+		return
+	}
+	p.handleHTTP(w, r)
+}
+
+// handleConnect tunnels the raw TCP connection, this is used for HTTPS
+func (p *Proxy) handleConnect(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "asb: hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+
+	upstream, err := p.dialer.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("asb: failed to connect to %s", r.Host), http.StatusBadGateway)
+		return
+	}
+
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		log.Error().
+			Err(err).
+			Msg("Egress proxy failed to hijack connection")
+		return
+	}
+
+	if _, err = client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		_ = client.Close()
+		_ = upstream.Close()
+		return
+	}
+
+	go func() {
+		// Forward anything the client sent after the CONNECT request
+		_, _ = io.Copy(upstream, buffered)
+		closeWrite(upstream)
+	}()
+	_, _ = io.Copy(client, upstream)
+	_ = client.Close()
+	_ = upstream.Close()
+}
+
+// handleHTTP forwards a plain-text HTTP request
+func (p *Proxy) handleHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Scheme != "http" || r.URL.Host == "" {
+		http.Error(w, "asb: only absolute http:// URLs can be proxied", http.StatusBadRequest)
+		return
+	}
+
+	outRequest := r.Clone(r.Context())
+	outRequest.RequestURI = ""
+	removeHopByHopHeaders(outRequest.Header)
+
+	response, err := p.transport.RoundTrip(outRequest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("asb: failed to reach %s", r.URL.Host), http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	removeHopByHopHeaders(response.Header)
+	for key, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+	_, _ = io.Copy(w, response.Body)
+}
+
+func removeHopByHopHeaders(header http.Header) {
+	for _, name := range header.Values("Connection") {
+		for field := range strings.SplitSeq(name, ",") {
+			header.Del(strings.TrimSpace(field))
+		}
+	}
+	for _, name := range _hopByHopHeaders {
+		header.Del(name)
+	}
+}
+
+func closeWrite(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.CloseWrite()
+		return
+	}
+	_ = conn.Close()
+}
+
+func normalizeDomains(domains []string) []string {
+	result := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" && !slices.Contains(result, domain) {
+			result = append(result, domain)
+		}
+	}
+	return result
+}
+
+func domainMatches(pattern string, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return host == suffix || strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
@@ -0,0 +1,149 @@
+package egressproxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestIsAllowed(t *testing.T) {
+	proxy := New([]string{"registry.npmjs.org", "*.crates.io", "Example.com:8443", " pypi.org "})
+	tests := []struct {
+		hostPort string
+		want     bool
+	}{
+		{hostPort: "registry.npmjs.org", want: true},
+		{hostPort: "registry.npmjs.org:443", want: true},
+		{hostPort: "registry.npmjs.org:80", want: true},
+		{hostPort: "REGISTRY.npmjs.org.:443", want: true},
+		{hostPort: "registry.npmjs.org:22", want: false},
+		{hostPort: "npmjs.org:443", want: false},
+		{hostPort: "evil.registry.npmjs.org:443", want: false},
+		{hostPort: "index.crates.io:443", want: true},
+		{hostPort: "static.index.crates.io:443", want: true},
+		{hostPort: "crates.io:443", want: true},
+		{hostPort: "evilcrates.io:443", want: false},
+		{hostPort: "crates.io.evil.com:443", want: false},
+		{hostPort: "example.com:8443", want: true},
+		{hostPort: "example.com:443", want: false},
+		{hostPort: "pypi.org:443", want: true},
+		{hostPort: "github.com:443", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hostPort, func(t *testing.T) {
+			if got := proxy.IsAllowed(tt.hostPort); got != tt.want {
+				t.Errorf("IsAllowed(%q) = %t, want %t", tt.hostPort, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyHTTP(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Connection") != "" {
+			t.Errorf("Hop-by-hop header Proxy-Connection was forwarded")
+		}
+		_, _ = io.WriteString(w, "hello over http")
+	}))
+	defer upstream.Close()
+
+	client := newProxiedClient(t, []string{upstream.Listener.Addr().String()}, nil)
+	request, err := http.NewRequestWithContext(context.Background(), http.MethodGet, upstream.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Proxy-Connection", "keep-alive")
+
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", upstream.URL, err)
+	}
+	defer func() { _ = response.Body.Close() }()
+	assertResponse(t, response, http.StatusOK, "hello over http")
+}
+
+func TestProxyConnect(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "hello over https")
+	}))
+	defer upstream.Close()
+
+	client := newProxiedClient(t, []string{upstream.Listener.Addr().String()}, upstream.Client().Transport)
+	response, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", upstream.URL, err)
+	}
+	defer func() { _ = response.Body.Close() }()
+	assertResponse(t, response, http.StatusOK, "hello over https")
+}
+
+func TestProxyDeniesHost(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Errorf("Request to a denied host reached it")
+	}))
+	defer upstream.Close()
+
+	client := newProxiedClient(t, []string{"registry.npmjs.org"}, nil)
+	response, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", upstream.URL, err)
+	}
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Status = %d, want %d", response.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestProxyDeniesConnect(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Errorf("Request to a denied host reached it")
+	}))
+	defer upstream.Close()
+
+	// The port is not allowed, only the host
+	host, _, err := net.SplitHostPort(upstream.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newProxiedClient(t, []string{host}, upstream.Client().Transport)
+	response, err := client.Get(upstream.URL)
+	if err == nil {
+		_ = response.Body.Close()
+		t.Fatalf("GET %s succeeded, want the CONNECT to be denied", upstream.URL)
+	}
+}
+
+// newProxiedClient starts a proxy allowing the domains and returns a client that goes through it.
+// The TLS config of baseTransport, if any, is kept, e.g. to trust a test server.
+func newProxiedClient(t *testing.T, allowedDomains []string, baseTransport http.RoundTripper) *http.Client {
+	t.Helper()
+	proxy := New(allowedDomains)
+	address, err := proxy.Start(context.Background(), "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start proxy: %v", err)
+	}
+	t.Cleanup(func() { _ = proxy.Close() })
+
+	transport := &http.Transport{}
+	if base, ok := baseTransport.(*http.Transport); ok {
+		transport = base.Clone()
+	}
+	transport.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: address})
+	return &http.Client{Transport: transport}
+}
+
+func assertResponse(t *testing.T, response *http.Response, wantStatus int, wantBody string) {
+	t.Helper()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	if response.StatusCode != wantStatus || string(body) != wantBody {
+		t.Errorf("Got %d %q, want %d %q", response.StatusCode, body, wantStatus, wantBody)
+	}
+}
@@ -0,0 +1,219 @@
+package ignorefile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Matcher matches paths against the patterns of an ignore file, which uses the gitignore syntax
+type Matcher struct {
+	rules []rule
+}
+
+type rule struct {
+	regex    *regexp.Regexp
+	negate   bool             // Pattern starts with "!", re-including the paths matched by earlier patterns
+	dirOnly  bool             // Pattern ends with "/", matching only directories
+	segments []*regexp.Regexp // Segments of a pattern relative to the directory of the ignore file, nil for "**"
+}
+
+// Load reads the ignore file, a missing file returns a nil Matcher
+func Load(file string) (*Matcher, error) {
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	matcher, err := Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return matcher, nil
+}
+
+// Parse parses the content of an ignore file, one pattern per line, "#" starts a comment
+func Parse(content []byte) (*Matcher, error) {
+	matcher := &Matcher{}
+	for i, line := range strings.Split(string(bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))), "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r rule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		regex, err := regexp.Compile(patternToRegex(line))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q on line %d: %w", line, i+1, err)
+		}
+		r.regex = regex
+		if strings.Contains(line, "/") {
+			r.segments = patternToSegmentRegexes(line)
+		}
+		matcher.rules = append(matcher.rules, r)
+	}
+	return matcher, nil
+}
+
+// Match returns true if the path, relative to the directory of the ignore file and with "/" as
+// the separator, is ignored. The last matching pattern wins. Paths inside an ignored directory are
+// not matched by the directory's pattern, the caller is expected not to descend into it.
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.regex.MatchString(relPath) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// MayMatchInside returns false if no path inside the directory, relative to the directory of the ignore file
+// and with "/" as the separator, can be ignored, so that the caller can skip it. A pattern without a "/",
+// other than a trailing one, matches a name at any depth, so it can match inside any directory.
+func (m *Matcher) MayMatchInside(relDir string) bool {
+	dirSegments := strings.Split(relDir, "/")
+	for _, r := range m.rules {
+		if r.negate {
+			// Only re-includes paths
+			continue
+		}
+		if r.segments == nil || mayMatchBelow(r.segments, dirSegments) {
+			return true
+		}
+	}
+	return false
+}
+
+// mayMatchBelow returns true if the segments of a pattern can match a path below the directory
+func mayMatchBelow(segments []*regexp.Regexp, dirSegments []string) bool {
+	for i, dirSegment := range dirSegments {
+		if i == len(segments) {
+			// The pattern matches at most the directory itself
+			return false
+		}
+		if segments[i] == nil {
+			// "**" matches any number of directories
+			return true
+		}
+		if !segments[i].MatchString(dirSegment) {
+			return false
+		}
+	}
+	return len(segments) > len(dirSegments)
+}
+
+// patternToRegex converts a gitignore pattern to a regex. A pattern with a "/", other than a trailing one,
+// is relative to the directory of the ignore file, otherwise it matches a name at any depth.
+func patternToRegex(pattern string) string {
+	var regex strings.Builder
+	if strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+		regex.WriteString("^")
+	} else {
+		regex.WriteString("^(?:.*/)?")
+	}
+
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		isLast := i == len(segments)-1
+		switch {
+		case segment == "**" && isLast:
+			// Everything inside the directory
+			regex.WriteString(".*")
+		case segment == "**":
+			// Zero or more directories
+			regex.WriteString("(?:.*/)?")
+		default:
+			regex.WriteString(globToRegex(segment))
+			if !isLast {
+				regex.WriteString("/")
+			}
+		}
+	}
+	regex.WriteString("$")
+	return regex.String()
+}
+
+// patternToSegmentRegexes converts a pattern relative to the directory of the ignore file to a regex per
+// segment, nil for "**". The patterns are already known to be valid.
+func patternToSegmentRegexes(pattern string) []*regexp.Regexp {
+	segments := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	regexes := make([]*regexp.Regexp, 0, len(segments))
+	for _, segment := range segments {
+		if segment == "**" {
+			regexes = append(regexes, nil)
+			continue
+		}
+		regexes = append(regexes, regexp.MustCompile("^"+globToRegex(segment)+"$"))
+	}
+	return regexes
+}
+
+// globToRegex converts a segment of a gitignore pattern, i.e. without "/", to a regex
+func globToRegex(segment string) string {
+	var regex strings.Builder
+	for i := 0; i < len(segment); i++ {
+		switch {
+		case segment[i] == '*':
+			regex.WriteString("[^/]*")
+		case segment[i] == '?':
+			regex.WriteString("[^/]")
+		case segment[i] == '[':
+			class, length := parseCharClass(segment[i:])
+			regex.WriteString(class)
+			i += length - 1
+		case segment[i] == '\\' && i+1 < len(segment):
+			i++
+			regex.WriteString(regexp.QuoteMeta(segment[i : i+1]))
+		default:
+			regex.WriteString(regexp.QuoteMeta(segment[i : i+1]))
+		}
+	}
+	return regex.String()
+}
+
+// parseCharClass converts a bracket expression like "[a-z]" or "[!0-9]" at the start of pattern to a regex,
+// and returns the number of bytes it spans. An unclosed "[" is a literal.
+func parseCharClass(pattern string) (string, int) {
+	end := strings.IndexByte(pattern[1:], ']')
+	if end == 0 {
+		// "]" right after the "[" is part of the class, e.g. "[]a]"
+		if next := strings.IndexByte(pattern[2:], ']'); next >= 0 {
+			end = next + 1
+		} else {
+			end = -1
+		}
+	}
+	if end < 0 {
+		return regexp.QuoteMeta("["), 1
+	}
+
+	class := strings.ReplaceAll(pattern[1:end+1], `\`, `\\`)
+	if strings.HasPrefix(class, "!") {
+		// Like "*" and "?", a negated class never matches the separator
+		return "[^" + class[1:] + "/]", end + 2
+	}
+	return "[" + class + "]", end + 2
+}
@@ -0,0 +1,97 @@
+package ignorefile
+
+import (
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{name: "name at root", patterns: ".env", path: ".env", want: true},
+		{name: "name at any depth", patterns: ".env", path: "app/config/.env", want: true},
+		{name: "name is not a suffix", patterns: ".env", path: "app/x.env", want: false},
+		{name: "anchored at root", patterns: "/.env", path: ".env", want: true},
+		{name: "anchored not at depth", patterns: "/.env", path: "app/.env", want: false},
+		{name: "middle slash anchors", patterns: ".git/config", path: ".git/config", want: true},
+		{name: "middle slash not at depth", patterns: ".git/config", path: "sub/.git/config", want: false},
+		{name: "star in name", patterns: "*.pem", path: "certs/server.pem", want: true},
+		{name: "star does not cross directories", patterns: "certs/*.pem", path: "certs/a/server.pem", want: false},
+		{name: "question mark", patterns: "key?.pem", path: "key1.pem", want: true},
+		{name: "question mark needs a character", patterns: "key?.pem", path: "key.pem", want: false},
+		{name: "leading double star", patterns: "**/secrets.json", path: "secrets.json", want: true},
+		{name: "leading double star at depth", patterns: "**/secrets.json", path: "a/b/secrets.json", want: true},
+		{name: "middle double star", patterns: "config/**/prod.yaml", path: "config/prod.yaml", want: true},
+		{name: "middle double star at depth", patterns: "config/**/prod.yaml", path: "config/a/b/prod.yaml", want: true},
+		{name: "trailing double star", patterns: "secrets/**", path: "secrets/a/b.txt", want: true},
+		{name: "trailing double star not the directory", patterns: "secrets/**", path: "secrets", isDir: true, want: false},
+		{name: "trailing slash matches directory", patterns: "secrets/", path: "app/secrets", isDir: true, want: true},
+		{name: "trailing slash skips file", patterns: "secrets/", path: "app/secrets", want: false},
+		{name: "negation re-includes", patterns: "*.pem\n!test/fixtures/*.pem", path: "test/fixtures/a.pem", want: false},
+		{name: "negation keeps the others", patterns: "*.pem\n!test/fixtures/*.pem", path: "certs/a.pem", want: true},
+		{name: "last pattern wins", patterns: "!a.pem\n*.pem", path: "a.pem", want: true},
+		{name: "character class", patterns: "key[0-9].pem", path: "key7.pem", want: true},
+		{name: "character class no match", patterns: "key[0-9].pem", path: "keyx.pem", want: false},
+		{name: "negated character class", patterns: "key[!0-9].pem", path: "keyx.pem", want: true},
+		{name: "negated character class no match", patterns: "key[!0-9].pem", path: "key7.pem", want: false},
+		{name: "negated character class not the separator", patterns: "a[!x]b", path: "a/b", want: false},
+		{name: "bracket first in class", patterns: "[]a].txt", path: "].txt", want: true},
+		{name: "unclosed bracket is literal", patterns: "a[b", path: "a[b", want: true},
+		{name: "escaped star", patterns: `\*.txt`, path: "*.txt", want: true},
+		{name: "escaped star is literal", patterns: `\*.txt`, path: "a.txt", want: false},
+		{name: "escaped negation", patterns: `\!important`, path: "!important", want: true},
+		{name: "comment", patterns: "# .env", path: "# .env", want: false},
+		{name: "dot is literal", patterns: "a.txt", path: "abtxt", want: false},
+		{name: "windows line endings", patterns: ".env\r\n*.pem\r\n", path: "a.pem", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := Parse([]byte(tt.patterns))
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.patterns, err)
+			}
+			if got := matcher.Match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Match(%q, %t) with %q = %t, want %t", tt.path, tt.isDir, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMayMatchInside(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns string
+		dir      string
+		want     bool
+	}{
+		{name: "name at any depth", patterns: ".env", dir: "node_modules", want: true},
+		{name: "anchored at root", patterns: "/.env", dir: "node_modules", want: false},
+		{name: "prefix of the pattern", patterns: "config/prod/secrets.json", dir: "config/prod", want: true},
+		{name: "other directory", patterns: "config/prod/secrets.json", dir: "node_modules", want: false},
+		{name: "the matched path itself", patterns: "config/prod", dir: "config/prod", want: false},
+		{name: "glob segment", patterns: "*/secrets.json", dir: "app", want: true},
+		{name: "glob segment too deep", patterns: "*/secrets.json", dir: "app/sub", want: false},
+		{name: "double star", patterns: "config/**/prod.yaml", dir: "config/a/b", want: true},
+		{name: "double star other directory", patterns: "config/**/prod.yaml", dir: "src", want: false},
+		{name: "leading double star", patterns: "**/secrets.json", dir: "a/b", want: true},
+		{name: "negation only", patterns: "!/config/a", dir: "config", want: false},
+		{name: "any rule", patterns: "/.env\nconfig/a", dir: "config", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := Parse([]byte(tt.patterns))
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.patterns, err)
+			}
+			if got := matcher.MayMatchInside(tt.dir); got != tt.want {
+				t.Errorf("MayMatchInside(%q) with %q = %t, want %t", tt.dir, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
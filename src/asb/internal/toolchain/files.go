@@ -0,0 +1,143 @@
+package toolchain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Project files that request a toolchain version, and how to read the version from them
+var _versionFileParsers = map[string]func(content []byte) (string, error){
+	".python-version":     readFirstLine,
+	"pyproject.toml":      readTOMLString(`requires-python`),
+	".nvmrc":              readFirstLine,
+	".node-version":       readFirstLine,
+	"package.json":        readPackageJSONEngine,
+	"rust-toolchain.toml": readTOMLString(`channel`),
+	"rust-toolchain":      readRustToolchain,
+	".ruby-version":       readRubyVersion,
+}
+
+// Matches versions that can be used, as opposed to aliases like "lts/*", "stable" or "nightly"
+var _numericVersionRegex = regexp.MustCompile(`^[v\d<>=!~^*]`)
+
+// IsSupportedFile returns true if the version can be read from the file with the given name
+func IsSupportedFile(name string) bool {
+	_, ok := _versionFileParsers[name]
+	return ok
+}
+
+// FindVersion looks for the first of the files that requests a version in workingDir and its parents,
+// up to the repository root. It returns the requested version, e.g. "3.11" or ">=18", and the path
+// of the file, or empty strings if none of the files requests one.
+func FindVersion(workingDir string, files []string) (string, string, error) {
+	dir, err := filepath.Abs(workingDir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get absolute path of %s: %w", workingDir, err)
+	}
+
+	for {
+		for _, name := range files {
+			requested, path, err := readVersionFile(filepath.Join(dir, name))
+			if err != nil || requested != "" {
+				return requested, path, err
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			// Reached the repository root
+			return "", "", nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", nil
+		}
+		dir = parent
+	}
+}
+
+func readVersionFile(path string) (string, string, error) {
+	parse, ok := _versionFileParsers[filepath.Base(path)]
+	if !ok {
+		return "", "", fmt.Errorf("reading the version from %s is not supported", filepath.Base(path))
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	requested, err := parse(content)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the version from %s: %w", path, err)
+	}
+
+	requested = strings.TrimSpace(requested)
+	if requested != "" && !_numericVersionRegex.MatchString(requested) {
+		log.Debug().
+			Str("file", path).
+			Str("version", requested).
+			Msg("Ignoring version alias, using the default image")
+		return "", "", nil
+	}
+	return requested, path, nil
+}
+
+// readFirstLine returns the first line that is neither empty nor a comment
+func readFirstLine(content []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// readTOMLString returns a parser for a top-level or table string value, e.g. `channel = "1.80.0"`.
+// It does not handle all of TOML, but the files it reads are simple.
+func readTOMLString(key string) func(content []byte) (string, error) {
+	keyRegex := regexp.MustCompile(`(?m)^\s*` + regexp.QuoteMeta(key) + `\s*=\s*["']([^"']*)["']`)
+	return func(content []byte) (string, error) {
+		if match := keyRegex.FindSubmatch(content); match != nil {
+			return string(match[1]), nil
+		}
+		return "", nil
+	}
+}
+
+func readPackageJSONEngine(content []byte) (string, error) {
+	var packageJSON struct {
+		Engines map[string]string `json:"engines"`
+	}
+	if err := json.Unmarshal(content, &packageJSON); err != nil {
+		return "", err
+	}
+	return packageJSON.Engines["node"], nil
+}
+
+// readRustToolchain reads the legacy rust-toolchain file, which is either a channel name or TOML
+func readRustToolchain(content []byte) (string, error) {
+	if bytes.Contains(content, []byte("[toolchain]")) {
+		return readTOMLString("channel")(content)
+	}
+	return readFirstLine(content)
+}
+
+func readRubyVersion(content []byte) (string, error) {
+	line, err := readFirstLine(content)
+	return strings.TrimPrefix(line, "ruby-"), err
+}
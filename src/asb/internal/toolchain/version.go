@@ -0,0 +1,200 @@
+package toolchain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A version with up to three components, e.g. "3.11" or "20.11.1"
+var _exactVersionRegex = regexp.MustCompile(`^v?\d+(\.\d+){0,2}$`)
+
+// Comparison operators, longest first so that ">=" is not read as ">"
+var _operators = []string{">=", "<=", "==", "!=", "~=", ">", "<", "=", "^", "~"}
+
+// Joins operators with the version that follows them, e.g. ">= 18" becomes ">=18"
+var _operatorSpaceRegex = regexp.MustCompile(`([<>=!~^])\s+`)
+
+// version holds the numeric components of a version, missing components are zero
+type version [3]int
+
+// interval is the half-open range of versions [from, to)
+type interval struct {
+	from version
+	to   version
+}
+
+var _maxVersion = version{math.MaxInt, math.MaxInt, math.MaxInt}
+
+// Select returns the version to use for the requested one.
+// An exact version, e.g. "3.11.4", is used as is, cut to precision components if precision is non-zero.
+// A range, e.g. ">=3.10" or "^20", selects the newest of the known versions that is in it.
+func Select(requested string, versions []string, precision int) (string, error) {
+	requested = strings.TrimSpace(requested)
+	if _exactVersionRegex.MatchString(requested) {
+		components := strings.Split(strings.TrimPrefix(requested, "v"), ".")
+		if precision > 0 && len(components) > precision {
+			components = components[:precision]
+		}
+		return strings.Join(components, "."), nil
+	}
+
+	matches, err := parseRange(requested)
+	if err != nil {
+		return "", err
+	}
+
+	selected := ""
+	var selectedVersion version
+	for _, candidate := range versions {
+		candidateVersion, length, err := parseVersion(candidate)
+		if err != nil {
+			return "", err
+		}
+		if matches(candidateVersion, length) && (selected == "" || compare(candidateVersion, selectedVersion) > 0) {
+			selected, selectedVersion = candidate, candidateVersion
+		}
+	}
+
+	if selected == "" {
+		return "", fmt.Errorf("none of the known versions %q is in the range %q", versions, requested)
+	}
+	return selected, nil
+}
+
+// parseRange parses a version range in either npm or PEP 440 syntax, e.g. ">=18 <21 || ^22" or ">=3.10,<3.13".
+// The returned function reports whether any version of a candidate family, e.g. "3.11" for all
+// of 3.11.x, is in the range.
+func parseRange(value string) (func(candidate version, length int) bool, error) {
+	alternatives := make([][]func(version, int) bool, 0)
+	for alternative := range strings.SplitSeq(value, "||") {
+		normalized := _operatorSpaceRegex.ReplaceAllString(strings.TrimSpace(alternative), "$1")
+		comparators := make([]func(version, int) bool, 0)
+		for _, comparator := range strings.FieldsFunc(normalized, isRangeSeparator) {
+			matches, err := parseComparator(comparator)
+			if err != nil {
+				return nil, fmt.Errorf("unsupported version range %q: %w", value, err)
+			}
+			comparators = append(comparators, matches)
+		}
+		if len(comparators) == 0 {
+			return nil, fmt.Errorf("unsupported version range %q", value)
+		}
+		alternatives = append(alternatives, comparators)
+	}
+
+	return func(candidate version, length int) bool {
+		return slices.ContainsFunc(alternatives, func(comparators []func(version, int) bool) bool {
+			for _, matches := range comparators {
+				if !matches(candidate, length) {
+					return false
+				}
+			}
+			return true
+		})
+	}, nil
+}
+
+func isRangeSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '\t'
+}
+
+func parseComparator(comparator string) (func(version, int) bool, error) {
+	operator := ""
+	for _, candidate := range _operators {
+		if strings.HasPrefix(comparator, candidate) {
+			operator = candidate
+			break
+		}
+	}
+
+	value := strings.TrimPrefix(comparator, operator)
+	value = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(value, ".*"), ".x"), ".X")
+	if value == "*" || value == "x" || value == "X" {
+		return func(version, int) bool { return true }, nil
+	}
+
+	v, length, err := parseVersion(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed interval
+	switch operator {
+	case ">=":
+		allowed = interval{from: v, to: _maxVersion}
+	case ">":
+		allowed = interval{from: next(v, length-1), to: _maxVersion}
+	case "<":
+		allowed = interval{to: v}
+	case "<=":
+		allowed = interval{to: next(v, length-1)}
+	case "^":
+		// Allows changes that do not modify the first non-zero component
+		index := slices.IndexFunc(v[:length], func(component int) bool { return component != 0 })
+		if index == -1 {
+			index = length - 1
+		}
+		allowed = interval{from: v, to: next(v, index)}
+	case "~":
+		allowed = interval{from: v, to: next(v, min(length-1, 1))}
+	case "~=":
+		if length < 2 {
+			return nil, fmt.Errorf("%q needs at least two version components", comparator)
+		}
+		allowed = interval{from: v, to: next(v, length-2)}
+	case "!=":
+		excluded := interval{from: v, to: next(v, length-1)}
+		return func(candidate version, candidateLength int) bool {
+			family := getFamily(candidate, candidateLength)
+			return compare(family.from, excluded.from) < 0 || compare(family.to, excluded.to) > 0
+		}, nil
+	default:
+		// "==", "=" or no operator match the version and all of its sub-versions
+		allowed = interval{from: v, to: next(v, length-1)}
+	}
+
+	return func(candidate version, candidateLength int) bool {
+		family := getFamily(candidate, candidateLength)
+		return compare(family.from, allowed.to) < 0 && compare(allowed.from, family.to) < 0
+	}, nil
+}
+
+// getFamily returns all the versions that a partial version stands for, e.g. 3.11.x for "3.11"
+func getFamily(v version, length int) interval {
+	return interval{from: v, to: next(v, length-1)}
+}
+
+// next returns the smallest version after all of v's sub-versions at the index,
+// e.g. 3.12.0 for index 1 of 3.11.4
+func next(v version, index int) version {
+	var result version
+	copy(result[:index], v[:index])
+	result[index] = v[index] + 1
+	return result
+}
+
+func parseVersion(value string) (version, int, error) {
+	var v version
+	components := strings.Split(strings.TrimPrefix(value, "v"), ".")
+	if len(components) > len(v) {
+		return v, 0, fmt.Errorf("version %q has too many components", value)
+	}
+
+	for i, component := range components {
+		number, err := strconv.Atoi(component)
+		if err != nil || number < 0 {
+			return v, 0, errors.New("invalid version " + strconv.Quote(value))
+		}
+		v[i] = number
+	}
+	return v, len(components), nil
+}
+
+func compare(a version, b version) int {
+	return slices.Compare(a[:], b[:])
+}
@@ -0,0 +1,85 @@
+package toolchain
+
+import (
+	"testing"
+)
+
+var (
+	_nodeVersions   = []string{"18", "20", "22", "24", "25"}
+	_pythonVersions = []string{"3.9", "3.10", "3.11", "3.12", "3.13", "3.14"}
+)
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		requested string
+		versions  []string
+		precision int
+		want      string
+		wantErr   bool
+	}{
+		// Exact versions are used as is, cut to the precision
+		{requested: "20", versions: _nodeVersions, want: "20"},
+		{requested: "21", versions: _nodeVersions, want: "21"},
+		{requested: "v20.11.1", versions: _nodeVersions, want: "20.11.1"},
+		{requested: "20.11.1", versions: _nodeVersions, precision: 1, want: "20"},
+		{requested: " 3.11.4\n", versions: _pythonVersions, precision: 2, want: "3.11"},
+		{requested: "3.11", versions: _pythonVersions, precision: 2, want: "3.11"},
+
+		// Caret keeps the first non-zero component
+		{requested: "^20", versions: _nodeVersions, want: "20"},
+		{requested: "^20.5.1", versions: _nodeVersions, want: "20"},
+		{requested: "^3.10", versions: _pythonVersions, want: "3.14"},
+		{requested: "^0.3", versions: []string{"0.2", "0.3", "0.4"}, want: "0.3"},
+
+		// Tilde keeps the minor version, or the major one if there is no minor
+		{requested: "~3.11", versions: _pythonVersions, want: "3.11"},
+		{requested: "~3.11.2", versions: _pythonVersions, want: "3.11"},
+		{requested: "~3", versions: _pythonVersions, want: "3.14"},
+		{requested: "~=3.10", versions: _pythonVersions, want: "3.14"},
+		{requested: "~=3.10.2", versions: _pythonVersions, want: "3.10"},
+
+		// Comparisons
+		{requested: ">=18", versions: _nodeVersions, want: "25"},
+		{requested: ">= 18 < 21", versions: _nodeVersions, want: "20"},
+		{requested: "<22", versions: _nodeVersions, want: "20"},
+		{requested: "<=22", versions: _nodeVersions, want: "22"},
+		{requested: ">3.12", versions: _pythonVersions, want: "3.14"},
+		{requested: ">=3.10,<3.13", versions: _pythonVersions, want: "3.12"},
+		{requested: ">=3.10, <3.13", versions: _pythonVersions, want: "3.12"},
+		{requested: ">=3.9,!=3.14", versions: _pythonVersions, want: "3.13"},
+		{requested: ">=3.12.1", versions: []string{"3.11", "3.12"}, want: "3.12"},
+
+		// Wildcards and alternatives
+		{requested: "==3.11.*", versions: _pythonVersions, want: "3.11"},
+		{requested: "22.x", versions: _nodeVersions, want: "22"},
+		{requested: "*", versions: _nodeVersions, want: "25"},
+		{requested: "^18 || ^22", versions: _nodeVersions, want: "22"},
+		{requested: "<19 || >=26", versions: _nodeVersions, want: "18"},
+
+		// No known version in the range
+		{requested: ">=26", versions: _nodeVersions, wantErr: true},
+		{requested: ">20 <22", versions: _nodeVersions, wantErr: true},
+		{requested: "<3.9", versions: _pythonVersions, wantErr: true},
+
+		// Bad input
+		{requested: "latest", versions: _nodeVersions, wantErr: true},
+		{requested: "", versions: _nodeVersions, wantErr: true},
+		{requested: ">=abc", versions: _nodeVersions, wantErr: true},
+		{requested: "~=3", versions: _pythonVersions, wantErr: true},
+		{requested: ">=1.2.3.4", versions: _nodeVersions, wantErr: true},
+		{requested: "^18 ||", versions: _nodeVersions, wantErr: true},
+		{requested: ">=18", versions: []string{"18", "lts"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.requested, func(t *testing.T) {
+			got, err := Select(tt.requested, tt.versions, tt.precision)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select(%q) error = %v, want error %t", tt.requested, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Select(%q) = %q, want %q", tt.requested, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package cmdrunner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes the file and its parent directories, going through a temporary file
+// so that a concurrent run never reads nor mounts a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), err)
+	}
+
+	// The pid keeps concurrent runs from writing to the same temporary file
+	tmpFile := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmpFile, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmpFile, err)
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		_ = os.Remove(tmpFile)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package cmdrunner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const _auditLogFileName = "audit.jsonl"
+
+// AuditStatus is the outcome of a sandboxed run
+type AuditStatus string
+
+const (
+	// AuditStatusStarted means the container started. A run whose last record has this status did not
+	// finish, e.g. asb was killed.
+	AuditStatusStarted AuditStatus = "started"
+	// AuditStatusExited means the container exited, with the exit code of the record
+	AuditStatusExited AuditStatus = "exited"
+	// AuditStatusFailed means the run failed, e.g. the image could not be pulled or the backend failed
+	AuditStatusFailed AuditStatus = "failed"
+	// AuditStatusRefused means the run was refused, by the policy or as it would expose a sensitive path
+	AuditStatusRefused AuditStatus = "refused"
+)
+
+// AuditRecord describes a sandboxed run. The audit log has one JSON line when the container starts,
+// and one when the run ends, or only the latter if it ends before the container starts.
+type AuditRecord struct {
+	Time       time.Time        `json:"time"`                // When the container started, or when the run ended if it did not
+	Container  string           `json:"container,omitempty"` // Name of the container, shared by the records of a run
+	Status     AuditStatus      `json:"status"`
+	Error      string           `json:"error,omitempty"`
+	CmdType    CmdType          `json:"cmdType"`
+	Backend    string           `json:"backend"`
+	Image      string           `json:"image"`
+	Digest     string           `json:"digest,omitempty"` // Registry digest of the image, empty for local images
+	Entrypoint []string         `json:"entrypoint,omitempty"`
+	Command    []string         `json:"command"`
+	WorkingDir string           `json:"workingDir"`
+	User       string           `json:"user"` // "uid:gid", empty means root
+	Hardened   bool             `json:"hardened"`
+	Network    string           `json:"network"`
+	Mounts     []ExplainedMount `json:"mounts"`
+	EnvFiles   []string         `json:"envFiles,omitempty"` // Values of the env vars are not logged, as they can be secrets
+
+	ExitCode        int     `json:"exitCode"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// AuditFilter selects the records returned by ReadAuditLog, zero values match everything
+type AuditFilter struct {
+	CmdType    CmdType
+	WorkingDir string // Matches the runs in this directory and its subdirectories
+	Since      time.Time
+	Until      time.Time
+}
+
+// SetAuditLog sets the file that a record of the run is appended to, empty means the default file
+func SetAuditLog(file string) Option {
+	return func(c *Config) {
+		c.auditLogFile = file
+	}
+}
+
+// DefaultAuditLogFile returns the audit log file used when none is set, e.g. ~/.cache/asb/audit.jsonl
+func DefaultAuditLogFile() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "asb", _auditLogFileName), nil
+}
+
+// newAuditRecord returns the record of the run of the spec, started at startTime
+func newAuditRecord(ctx context.Context, backend Backend, config Config, spec ContainerSpec,
+	startTime time.Time,
+) AuditRecord {
+	workingDir, err := filepath.Abs(spec.WorkingDir)
+	if err != nil {
+		workingDir = spec.WorkingDir
+	}
+
+	record := AuditRecord{
+		Time:       startTime.UTC(),
+		Container:  spec.Name,
+		CmdType:    config.cmdType,
+		Backend:    backend.Name(),
+		Image:      spec.Image,
+		Entrypoint: spec.Entrypoint,
+		Command:    spec.Cmd,
+		WorkingDir: workingDir,
+		User:       spec.User,
+		Hardened:   !config.insecure,
+		Network:    spec.Network,
+		Mounts:     explainMounts(spec.Mounts),
+		EnvFiles:   spec.EnvFiles,
+	}
+	if digests, err := backend.ImageDigests(ctx, spec.Image); err == nil && len(digests) > 0 {
+		record.Digest = digests[0]
+	}
+	return record
+}
+
+// recordAuditFailure records a run that ended before its container started, e.g. refused by the policy.
+// Only the config is known at that point, not the spec.
+func recordAuditFailure(config Config, runErr error) {
+	status := AuditStatusFailed
+	var policyErr *PolicyViolationError
+	var sensitivePathErr *SensitivePathError
+	if errors.As(runErr, &policyErr) || errors.As(runErr, &sensitivePathErr) {
+		status = AuditStatusRefused
+	}
+
+	workingDir, err := filepath.Abs(config.workingDir)
+	if err != nil {
+		workingDir = config.workingDir
+	}
+
+	recordAudit(config, AuditRecord{
+		Time:       time.Now().UTC(),
+		Status:     status,
+		Error:      runErr.Error(),
+		CmdType:    config.cmdType,
+		Backend:    string(config.backendType),
+		Image:      config.getImage(),
+		Entrypoint: config.entrypoint,
+		Command:    config.args,
+		WorkingDir: workingDir,
+		Hardened:   !config.insecure,
+		Network:    string(config.networkType),
+	})
+}
+
+// recordAudit appends the record to the audit log, failing to do so does not fail the run
+func recordAudit(config Config, record AuditRecord) {
+	if err := appendAuditRecord(config.auditLogFile, record); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("Failed to write audit log")
+	}
+}
+
+func appendAuditRecord(file string, record AuditRecord) error {
+	if file == "" {
+		var err error
+		if file, err = DefaultAuditLogFile(); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(file), err)
+	}
+
+	// A single write in append mode, so that the records of concurrent runs do not interleave
+	auditLog, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", file, err)
+	}
+	if _, err = auditLog.Write(append(line, '\n')); err != nil {
+		_ = auditLog.Close()
+		return fmt.Errorf("failed to write audit log %s: %w", file, err)
+	}
+	return auditLog.Close()
+}
+
+// ReadAuditLog returns the records of the audit log that match the filter, oldest first, one per run.
+// The record of the start of a run is replaced by the one of its end, if any. A missing audit log has no records.
+func ReadAuditLog(file string, filter AuditFilter) ([]AuditRecord, error) {
+	auditLog, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s: %w", file, err)
+	}
+	defer func() { _ = auditLog.Close() }()
+
+	records := make([]AuditRecord, 0)
+	startedRuns := make(map[string]int) // Container name to the index of its start record
+	scanner := bufio.NewScanner(auditLog)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var record AuditRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// E.g. a line cut short by a full disk, the other records are still useful
+			log.Warn().
+				Err(err).
+				Str("file", file).
+				Int("line", lineNumber).
+				Msg("Skipping invalid audit record")
+			continue
+		}
+
+		if i, found := startedRuns[record.Container]; found && record.Container != "" {
+			// Keeps the position and the start time of the run
+			record.Time = records[i].Time
+			records[i] = record
+			delete(startedRuns, record.Container)
+			continue
+		}
+		if record.Status == AuditStatusStarted {
+			startedRuns[record.Container] = len(records)
+		}
+		records = append(records, record)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log %s: %w", file, err)
+	}
+
+	// Filtered once the records of each run are merged, so that e.g. --until does not split a run
+	return slices.DeleteFunc(records, func(record AuditRecord) bool { return !filter.matches(record) }), nil
+}
+
+func (f AuditFilter) matches(record AuditRecord) bool {
+	if f.CmdType != "" && record.CmdType != f.CmdType {
+		return false
+	}
+	if f.WorkingDir != "" && !isSubPath(f.WorkingDir, record.WorkingDir) {
+		return false
+	}
+	if !f.Since.IsZero() && record.Time.Before(f.Since) {
+		return false
+	}
+	return f.Until.IsZero() || record.Time.Before(f.Until)
+}
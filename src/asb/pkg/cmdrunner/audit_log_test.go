@@ -0,0 +1,148 @@
+package cmdrunner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditFilterMatches(t *testing.T) {
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	record := AuditRecord{Time: start, CmdType: "node_npm", WorkingDir: "/src/app"}
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   bool
+	}{
+		{name: "empty filter", filter: AuditFilter{}, want: true},
+		{name: "same tool", filter: AuditFilter{CmdType: "node_npm"}, want: true},
+		{name: "other tool", filter: AuditFilter{CmdType: "python_uvx"}, want: false},
+		{name: "same directory", filter: AuditFilter{WorkingDir: "/src/app"}, want: true},
+		{name: "parent directory", filter: AuditFilter{WorkingDir: "/src"}, want: true},
+		{name: "parent directory with a slash", filter: AuditFilter{WorkingDir: "/src/"}, want: true},
+		{name: "subdirectory", filter: AuditFilter{WorkingDir: "/src/app/web"}, want: false},
+		{name: "sibling with the same prefix", filter: AuditFilter{WorkingDir: "/src/ap"}, want: false},
+		{name: "since before", filter: AuditFilter{Since: start.Add(-time.Minute)}, want: true},
+		{name: "since at the start", filter: AuditFilter{Since: start}, want: true},
+		{name: "since after", filter: AuditFilter{Since: start.Add(time.Minute)}, want: false},
+		{name: "until after", filter: AuditFilter{Until: start.Add(time.Minute)}, want: true},
+		{name: "until at the start", filter: AuditFilter{Until: start}, want: false},
+		{
+			name:   "all matching",
+			filter: AuditFilter{CmdType: "node_npm", WorkingDir: "/src", Since: start.Add(-time.Hour), Until: start.Add(time.Hour)},
+			want:   true,
+		},
+		{
+			name:   "one not matching",
+			filter: AuditFilter{CmdType: "node_npm", WorkingDir: "/other", Since: start.Add(-time.Hour)},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(record); got != tt.want {
+				t.Errorf("%+v matches %+v = %t, want %t", tt.filter, record, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadAuditLog(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit.jsonl")
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	records := []AuditRecord{
+		{Time: start, Container: "asb-1", Status: AuditStatusStarted, CmdType: "node_npm"},
+		{Time: start.Add(time.Second), Container: "asb-2", Status: AuditStatusStarted, CmdType: "python_uvx"},
+		{Time: start.Add(2 * time.Second), Status: AuditStatusRefused, CmdType: "node_npx", Error: "denied"},
+		{Time: start.Add(time.Hour), Container: "asb-1", Status: AuditStatusExited, CmdType: "node_npm", ExitCode: 1},
+		// A run that failed before the container started only has its end recorded
+		{Time: start.Add(2 * time.Hour), Status: AuditStatusFailed, CmdType: "node_npm", Error: "pull failed"},
+	}
+	for _, record := range records {
+		if err := appendAuditRecord(file, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	auditLog, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A line cut short and an empty line are skipped
+	if _, err = auditLog.WriteString("{\"time\":\n\n"); err != nil {
+		t.Fatal(err)
+	}
+	_ = auditLog.Close()
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []AuditRecord
+	}{
+		{
+			name: "all",
+			want: []AuditRecord{
+				// The end of a run replaces its start, keeping its position and start time
+				{Time: start, Container: "asb-1", Status: AuditStatusExited, CmdType: "node_npm", ExitCode: 1},
+				// A run without an end is still reported as started
+				records[1],
+				records[2],
+				records[4],
+			},
+		},
+		{
+			name:   "tool",
+			filter: AuditFilter{CmdType: "node_npm"},
+			want: []AuditRecord{
+				{Time: start, Container: "asb-1", Status: AuditStatusExited, CmdType: "node_npm", ExitCode: 1},
+				records[4],
+			},
+		},
+		{
+			// The run ended after the limit, but is kept whole as it started before it
+			name:   "until",
+			filter: AuditFilter{Until: start.Add(time.Minute)},
+			want: []AuditRecord{
+				{Time: start, Container: "asb-1", Status: AuditStatusExited, CmdType: "node_npm", ExitCode: 1},
+				records[1],
+				records[2],
+			},
+		},
+		{
+			name:   "since",
+			filter: AuditFilter{Since: start.Add(time.Minute)},
+			want:   []AuditRecord{records[4]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadAuditLog(file, tt.filter)
+			if err != nil {
+				t.Fatalf("ReadAuditLog() failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ReadAuditLog() returned %d records, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if !isSameAuditRecord(got[i], tt.want[i]) {
+					t.Errorf("Record %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadAuditLogMissingFile(t *testing.T) {
+	records, err := ReadAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"), AuditFilter{})
+	if err != nil || len(records) != 0 {
+		t.Errorf("ReadAuditLog() = %+v, %v, want no records", records, err)
+	}
+}
+
+// isSameAuditRecord compares the fields that the records of the tests set
+func isSameAuditRecord(a AuditRecord, b AuditRecord) bool {
+	return a.Time.Equal(b.Time) && a.Container == b.Container && a.Status == b.Status &&
+		a.CmdType == b.CmdType && a.Error == b.Error && a.ExitCode == b.ExitCode
+}
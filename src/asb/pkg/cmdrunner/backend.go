@@ -0,0 +1,158 @@
+package cmdrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+)
+
+type BackendType string
+
+const (
+	// BackendAuto picks Docker if its daemon is reachable, else Podman if it is installed
+	BackendAuto   BackendType = "auto"
+	BackendDocker BackendType = "docker"
+	BackendPodman BackendType = "podman"
+)
+
+var _backendTypes = []BackendType{BackendAuto, BackendDocker, BackendPodman}
+
+// Backend is a container engine that runs the sandbox
+type Backend interface {
+	// Name returns a human-readable name of the backend
+	Name() string
+	// Ping checks that the backend is installed and running
+	Ping(ctx context.Context) error
+	// Info describes the container engine behind the backend
+	Info(ctx context.Context) (BackendInfo, error)
+
+	ImageExists(ctx context.Context, image string) (bool, error)
+	PullImage(ctx context.Context, image string) error
+	// ResolveImageDigest returns the digest of the image's manifest in its registry, e.g. "sha256:..."
+	ResolveImageDigest(ctx context.Context, image string) (string, error)
+	// ImageDigests returns the registry digests of a local image
+	ImageDigests(ctx context.Context, image string) ([]string, error)
+
+	ListVolumes(ctx context.Context) ([]VolumeInfo, error)
+	VolumeExists(ctx context.Context, name string) (bool, error)
+	CreateVolume(ctx context.Context, name string, labels map[string]string) error
+	RemoveVolume(ctx context.Context, name string) error
+
+	// EnsureNetwork creates the network if it does not exist and returns its IPv4 gateway
+	EnsureNetwork(ctx context.Context, name string, internal bool) (string, error)
+
+	// Run runs the container till it exits and returns how it exited.
+	// The container is killed if ctx is done before that.
+	Run(ctx context.Context, spec ContainerSpec) (ExitState, error)
+}
+
+// BackendInfo describes the container engine behind a backend
+type BackendInfo struct {
+	Version     string
+	Rootless    bool   // Whether the engine runs without root privileges
+	UsernsRemap bool   // Whether the engine maps the users of all the containers to unprivileged host users
+	VolumeDir   string // Where the engine stores the volumes, on the engine's host
+	CPUs        int    // Number of CPUs available to the containers, e.g. the ones of the Docker Desktop VM
+}
+
+// VolumeInfo describes a volume of the backend
+type VolumeInfo struct {
+	Name   string
+	Labels map[string]string
+}
+
+// ParseBackendType parses a user provided backend type
+func ParseBackendType(value string) (BackendType, error) {
+	backendType := BackendType(value)
+	if !slices.Contains(_backendTypes, backendType) {
+		return "", fmt.Errorf("unsupported backend %q, supported backends are %q", value, _backendTypes)
+	}
+	return backendType, nil
+}
+
+func SetBackendType(backendType BackendType) Option {
+	return func(c *Config) {
+		c.backendType = backendType
+	}
+}
+
+// NewBackend returns a running backend of the given type
+func NewBackend(ctx context.Context, backendType BackendType) (Backend, error) {
+	switch backendType {
+	case BackendDocker:
+		return newRunningBackend(ctx, newDockerBackend)
+	case BackendPodman:
+		return newRunningBackend(ctx, newPodmanBackend)
+	case BackendAuto, "":
+		return detectBackend(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported backend %q", backendType)
+	}
+}
+
+func newRunningBackend[T Backend](ctx context.Context, newBackend func() (T, error)) (Backend, error) {
+	backend, err := newBackend()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDockerUnavailable, err)
+	}
+
+	if err = backend.Ping(ctx); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDockerUnavailable, err)
+	}
+
+	log.Debug().
+		Str("backend", backend.Name()).
+		Msg("Backend is installed and running")
+	return backend, nil
+}
+
+// detectBackend prefers Docker, as that's what most users have, and falls back to Podman
+func detectBackend(ctx context.Context) (Backend, error) {
+	dockerBackend, dockerErr := newRunningBackend(ctx, newDockerBackend)
+	if dockerErr == nil {
+		return dockerBackend, nil
+	}
+
+	if _, err := exec.LookPath(_podmanBinary); err != nil || os.Getenv("DOCKER_HOST") != "" {
+		// Podman is not installed, or the user explicitly pointed at a Docker daemon
+		return nil, dockerErr
+	}
+
+	podmanBackend, podmanErr := newRunningBackend(ctx, newPodmanBackend)
+	if podmanErr != nil {
+		return nil, errors.Join(dockerErr, podmanErr)
+	}
+	return podmanBackend, nil
+}
+
+// ensureImage pulls the image if it does not exist locally
+func ensureImage(ctx context.Context, backend Backend, image string) error {
+	exists, err := backend.ImageExists(ctx, image)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		log.Debug().
+			Str("image", image).
+			Msg("Docker image found locally")
+		return nil
+	}
+
+	log.Info().
+		Str("image", image).
+		Msg("Docker image not found locally, pulling from registry")
+	if err = backend.PullImage(ctx, image); err != nil {
+		return fmt.Errorf("failed to pull docker image %s: %w", image, err)
+	}
+
+	log.Info().
+		Str("image", image).
+		Msg("Successfully pulled docker image")
+	return nil
+}
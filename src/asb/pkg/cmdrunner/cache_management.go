@@ -0,0 +1,285 @@
+package cmdrunner
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// File that records when each cache volume was last used, Docker does not track that
+const _cacheUsageFileName = "cache-usage.json"
+
+// Scope of the copies of the cache volumes left behind by a run that was killed
+const _ephemeralVolumeScope = "ephemeral"
+
+// Names of the cache volumes created by the versions of asb that did not label them,
+// any other volume needs the label, so that e.g. a "home" volume of another program is never removed
+var _unlabeledVolumeNames = []string{
+	"npm1", "npm2", "bun1", "ruby1", "ruby2", "ruby3", "ruby4", "ruby5", "cargo1",
+	"pip312", "pip313", "pip314", "pip315", "uv1", "uv2", "poetry1",
+}
+
+// CacheVolumeInfo describes a cache volume created by asb
+type CacheVolumeInfo struct {
+	Name      string
+	Scope     string    // "global", "project:<dir>", "zone:<name>" or "ephemeral" for leftover copies
+	Tools     []CmdType // Tools that use this volume, empty if no tool uses it anymore
+	SizeBytes int64     // -1 if the size is not known
+	LastUsed  time.Time // Zero if the volume was not used since asb started tracking it
+}
+
+type cacheUsage struct {
+	LastUsed map[string]time.Time `json:"lastUsed"`
+}
+
+// ListCacheVolumes returns the cache volumes that exist in the backend
+func ListCacheVolumes(ctx context.Context, backendType BackendType) ([]CacheVolumeInfo, error) {
+	backend, err := NewBackend(ctx, backendType)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, err := findCacheVolumes(ctx, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(volumes))
+	for _, volume := range volumes {
+		names = append(names, volume.Name)
+	}
+	sizes := getVolumeSizes(ctx, backend, names)
+	usage := loadCacheUsage()
+	for i := range volumes {
+		volumes[i].SizeBytes = -1
+		if size, ok := sizes[volumes[i].Name]; ok {
+			volumes[i].SizeBytes = size
+		}
+		volumes[i].LastUsed = usage.LastUsed[volumes[i].Name]
+	}
+	return volumes, nil
+}
+
+// PruneCacheVolumes removes the cache volumes used by the given tool, or all of them if cmdType is empty.
+// Volumes shared with other tools are removed as well. It returns the names of the removed volumes.
+func PruneCacheVolumes(ctx context.Context, backendType BackendType, cmdType CmdType) ([]string, error) {
+	backend, err := NewBackend(ctx, backendType)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, err := findCacheVolumes(ctx, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make([]string, 0)
+	var errs []error
+	for _, volume := range volumes {
+		if cmdType != "" && !slices.Contains(volume.Tools, cmdType) {
+			continue
+		}
+
+		if err = backend.RemoveVolume(ctx, volume.Name); err != nil {
+			// Most likely the volume is used by a running sandbox
+			errs = append(errs, err)
+			continue
+		}
+
+		log.Debug().
+			Str("volume", volume.Name).
+			Msg("Removed cache volume")
+		removed = append(removed, volume.Name)
+	}
+
+	if err = forgetCacheUsage(removed); err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Failed to update cache usage file")
+	}
+	return removed, errors.Join(errs...)
+}
+
+// findCacheVolumes returns the volumes that are either labeled as asb cache volumes,
+// or have the name of one of the volumes of the older versions of asb, which did not label them
+func findCacheVolumes(ctx context.Context, backend Backend) ([]CacheVolumeInfo, error) {
+	volumes, err := backend.ListVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]CacheVolumeInfo, 0)
+	for _, volume := range volumes {
+		if _, ephemeral := volume.Labels[_ephemeralVolumeLabel]; ephemeral {
+			// Left behind by a run that was killed
+			result = append(result, CacheVolumeInfo{Name: volume.Name, Scope: _ephemeralVolumeScope})
+			continue
+		}
+
+		baseName, labeled := volume.Labels[_volumeLabel]
+		if !labeled {
+			if !slices.Contains(_unlabeledVolumeNames, volume.Name) {
+				continue
+			}
+			baseName = volume.Name
+		}
+
+		scope := cmp.Or(volume.Labels[_volumeScopeLabel], string(CacheScopeGlobal))
+		result = append(result, CacheVolumeInfo{Name: volume.Name, Scope: scope, Tools: getCacheVolumeTools(baseName)})
+	}
+
+	slices.SortFunc(result, func(a, b CacheVolumeInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result, nil
+}
+
+func getCacheVolumeByName(name string) (cacheVolume, bool) {
+	volume, found := _toolRegistry.CacheVolume(name)
+	return cacheVolume{name: volume.Name, target: volume.Target}, found
+}
+
+func getCacheVolumeTools(name string) []CmdType {
+	tools := make([]CmdType, 0)
+	for _, tool := range _toolRegistry.ToolsUsingCacheVolume(name) {
+		tools = append(tools, CmdType(tool))
+	}
+	return tools
+}
+
+// getVolumeSizes measures the volumes with "du" inside a helper container, as the volumes may live
+// inside a VM, e.g. with Docker Desktop. Sizes are in bytes.
+func getVolumeSizes(ctx context.Context, backend Backend, names []string) map[string]int64 {
+	sizes := make(map[string]int64, len(names))
+	if len(names) == 0 {
+		return sizes
+	}
+
+	targets := make([]string, 0, len(names))
+	mounts := make([]Mount, 0, len(names))
+	for _, name := range names {
+		target := path.Join("/volumes", name)
+		targets = append(targets, strconv.Quote(target))
+		mounts = append(mounts, Mount{Type: MountTypeVolume, Source: name, Target: target, ReadOnly: true})
+	}
+
+	// du exits non-zero on unreadable files, but still reports the rest
+	script := []string{"du -sk " + strings.Join(targets, " ") + " || true"}
+	output, err := runHelperContainer(ctx, backend, Config{}, script, mounts)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Failed to measure the cache volumes")
+		return sizes
+	}
+
+	// Each line is "<size in KiB>\t<path>"
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		sizeKiB, target, found := strings.Cut(scanner.Text(), "\t")
+		size, err := strconv.ParseInt(sizeKiB, 10, 64)
+		if !found || err != nil {
+			continue
+		}
+		sizes[path.Base(target)] = size << 10
+	}
+	return sizes
+}
+
+// recordCacheUsage marks the cache volumes of this config as used now
+func recordCacheUsage(config Config) {
+	err := updateCacheUsage(func(usage *cacheUsage) {
+		for _, volume := range config.getCacheVolumes() {
+			usage.LastUsed[config.getVolumeName(volume)] = time.Now().UTC()
+		}
+	})
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Failed to update cache usage file")
+	}
+}
+
+func forgetCacheUsage(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	return updateCacheUsage(func(usage *cacheUsage) {
+		for _, name := range names {
+			delete(usage.LastUsed, name)
+		}
+	})
+}
+
+func updateCacheUsage(update func(usage *cacheUsage)) error {
+	usageFile, err := getCacheUsageFile()
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(usageFile), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(usageFile), err)
+	}
+
+	// Else concurrent runs could both read the file, and the last one to write it would drop the other's update
+	unlock, err := lockFile(usageFile + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	usage := loadCacheUsage()
+	update(&usage)
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache usage: %w", err)
+	}
+
+	if err = writeFileAtomic(usageFile, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write cache usage file: %w", err)
+	}
+	return nil
+}
+
+func loadCacheUsage() cacheUsage {
+	usage := cacheUsage{LastUsed: make(map[string]time.Time)}
+	usageFile, err := getCacheUsageFile()
+	if err != nil {
+		return usage
+	}
+
+	data, err := os.ReadFile(usageFile)
+	if err != nil {
+		return usage
+	}
+
+	if err = json.Unmarshal(data, &usage); err != nil || usage.LastUsed == nil {
+		log.Debug().
+			Err(err).
+			Str("file", usageFile).
+			Msg("Ignoring invalid cache usage file")
+		return cacheUsage{LastUsed: make(map[string]time.Time)}
+	}
+	return usage
+}
+
+func getCacheUsageFile() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "asb", _cacheUsageFileName), nil
+}
@@ -0,0 +1,157 @@
+package cmdrunner
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+type CacheScopeType string
+
+const (
+	// CacheScopeGlobal shares the cache volumes between all projects
+	CacheScopeGlobal CacheScopeType = "global"
+	// CacheScopeProject gives each working directory its own cache volumes
+	CacheScopeProject CacheScopeType = "project"
+	// CacheScopeZone shares the cache volumes between the projects of the same named trust zone
+	CacheScopeZone CacheScopeType = "zone"
+)
+
+// Label of the throwaway copies of the cache volumes, so that leftovers can be found
+const _ephemeralVolumeLabel = "net.ashishb.asb.cache.ephemeral"
+
+// Zone names become part of the volume names
+var _cacheZoneRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_.]{0,31}$`)
+
+// CacheScope decides which sandboxes share the cache volumes
+type CacheScope struct {
+	Type CacheScopeType
+	Zone string // Name of the trust zone, only for CacheScopeZone
+}
+
+// ParseCacheScope parses a user provided cache scope.
+// A non-empty zone implies CacheScopeZone.
+func ParseCacheScope(scopeType string, zone string) (CacheScope, error) {
+	scope := CacheScope{Type: CacheScopeType(scopeType), Zone: zone}
+	if zone != "" && (scopeType == "" || scope.Type == CacheScopeGlobal) {
+		scope.Type = CacheScopeZone
+	}
+
+	switch scope.Type {
+	case CacheScopeGlobal, CacheScopeProject:
+		if zone != "" {
+			return CacheScope{}, fmt.Errorf("cache zone %q cannot be used with cache scope %q", zone, scopeType)
+		}
+		return scope, nil
+	case CacheScopeZone:
+		if !_cacheZoneRegex.MatchString(zone) {
+			return CacheScope{}, fmt.Errorf("invalid cache zone %q, it must match %s", zone, _cacheZoneRegex)
+		}
+		return scope, nil
+	default:
+		return CacheScope{}, fmt.Errorf("unsupported cache scope %q, supported scopes are %q",
+			scopeType, []CacheScopeType{CacheScopeGlobal, CacheScopeProject, CacheScopeZone})
+	}
+}
+
+func SetCacheScope(scope CacheScope) Option {
+	return func(c *Config) {
+		c.cacheScope = scope
+	}
+}
+
+// SetReadOnlyCache gives the tool throwaway copies of the cache volumes, so that anything
+// it installs is discarded when it exits and the cache stays as it was
+func SetReadOnlyCache(readOnlyCache bool) Option {
+	return func(c *Config) {
+		c.readOnlyCache = readOnlyCache
+	}
+}
+
+// volumeSuffix returns the suffix of the volume names in this scope
+func (s CacheScope) volumeSuffix(workingDir string) string {
+	switch s.Type {
+	case CacheScopeProject:
+		hash := sha256.Sum256([]byte(workingDir))
+		return "-p" + hex.EncodeToString(hash[:6])
+	case CacheScopeZone:
+		return "-z" + s.Zone
+	default:
+		return ""
+	}
+}
+
+// label returns a human-readable description of the scope, stored as a volume label
+func (s CacheScope) label(workingDir string) string {
+	switch s.Type {
+	case CacheScopeProject:
+		return string(CacheScopeProject) + ":" + workingDir
+	case CacheScopeZone:
+		return string(CacheScopeZone) + ":" + s.Zone
+	default:
+		return string(CacheScopeGlobal)
+	}
+}
+
+// createEphemeralCacheVolumes creates a throwaway copy of each cache volume of the tool and returns the
+// mapping from the cache volume names to their copies, plus a function that removes the copies.
+// The volumes are copied in full on every run, as an overlay would need the host paths of the volumes,
+// which e.g. Docker Desktop keeps inside its VM, so only the volumes of the tool are copied.
+func createEphemeralCacheVolumes(ctx context.Context, backend Backend, config Config) (map[string]string, func(), error) {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+
+	ephemeralVolumes := make(map[string]string)
+	removeAll := func() {
+		for _, name := range ephemeralVolumes {
+			// The copies must go even if ctx is cancelled
+			if err := backend.RemoveVolume(context.WithoutCancel(ctx), name); err != nil {
+				log.Warn().
+					Err(err).
+					Str("volume", name).
+					Msg("Failed to remove ephemeral cache volume")
+			}
+		}
+	}
+
+	script := make([]string, 0)
+	mounts := make([]Mount, 0)
+	for i, volume := range config.getCacheVolumes() {
+		name := config.getVolumeName(volume)
+		ephemeralName := fmt.Sprintf("%s-tmp-%s", name, hex.EncodeToString(suffix))
+		if err := backend.CreateVolume(ctx, ephemeralName, map[string]string{_ephemeralVolumeLabel: name}); err != nil {
+			removeAll()
+			return nil, nil, err
+		}
+		ephemeralVolumes[name] = ephemeralName
+
+		source := path.Join("/asb/src", strconv.Itoa(i))
+		target := path.Join("/asb/dst", strconv.Itoa(i))
+		mounts = append(mounts,
+			Mount{Type: MountTypeVolume, Source: name, Target: source, ReadOnly: true},
+			Mount{Type: MountTypeVolume, Source: ephemeralName, Target: target})
+		script = append(script, fmt.Sprintf("cp -a %s/. %s/", source, target))
+	}
+
+	if len(script) == 0 {
+		return ephemeralVolumes, removeAll, nil
+	}
+
+	log.Info().
+		Strs("volumes", slices.Sorted(maps.Keys(ephemeralVolumes))).
+		Msg("Copying cache volumes, changes made to the cache by this run will be discarded")
+	if _, err := runHelperContainer(ctx, backend, config, script, mounts); err != nil {
+		removeAll()
+		return nil, nil, fmt.Errorf("failed to copy cache volumes: %w", err)
+	}
+	return ephemeralVolumes, removeAll, nil
+}
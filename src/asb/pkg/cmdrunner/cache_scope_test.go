@@ -0,0 +1,70 @@
+package cmdrunner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCacheScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		scopeType string
+		zone      string
+		want      CacheScope
+		wantErr   bool
+	}{
+		{name: "global", scopeType: "global", want: CacheScope{Type: CacheScopeGlobal}},
+		{name: "project", scopeType: "project", want: CacheScope{Type: CacheScopeProject}},
+		{name: "zone", scopeType: "zone", zone: "experiments", want: CacheScope{Type: CacheScopeZone, Zone: "experiments"}},
+		{name: "zone without scope", zone: "experiments", want: CacheScope{Type: CacheScopeZone, Zone: "experiments"}},
+		{name: "zone overrides global", scopeType: "global", zone: "work.2", want: CacheScope{Type: CacheScopeZone, Zone: "work.2"}},
+		{name: "zone with project", scopeType: "project", zone: "experiments", wantErr: true},
+		{name: "zone scope without zone", scopeType: "zone", wantErr: true},
+		{name: "zone with uppercase", zone: "Experiments", wantErr: true},
+		{name: "zone with a slash", zone: "a/b", wantErr: true},
+		{name: "zone starting with a dot", zone: ".hidden", wantErr: true},
+		{name: "zone too long", zone: strings.Repeat("a", 33), wantErr: true},
+		{name: "longest zone", zone: strings.Repeat("a", 32), want: CacheScope{Type: CacheScopeZone, Zone: strings.Repeat("a", 32)}},
+		{name: "empty", wantErr: true},
+		{name: "unknown", scopeType: "machine", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCacheScope(tt.scopeType, tt.zone)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCacheScope(%q, %q) error = %v, want error %t", tt.scopeType, tt.zone, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCacheScope(%q, %q) = %+v, want %+v", tt.scopeType, tt.zone, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheScopeVolumeSuffix(t *testing.T) {
+	tests := []struct {
+		name       string
+		scope      CacheScope
+		workingDir string
+		want       string
+	}{
+		{name: "global", scope: CacheScope{Type: CacheScopeGlobal}, workingDir: "/src/a", want: ""},
+		{name: "zone", scope: CacheScope{Type: CacheScopeZone, Zone: "experiments"}, workingDir: "/src/a", want: "-zexperiments"},
+		// The first 6 bytes of the SHA-256 of the directory, changing it would orphan the existing volumes
+		{name: "project", scope: CacheScope{Type: CacheScopeProject}, workingDir: "/src/a", want: "-p3213ad7c2b80"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.volumeSuffix(tt.workingDir); got != tt.want {
+				t.Errorf("volumeSuffix(%q) = %q, want %q", tt.workingDir, got, tt.want)
+			}
+		})
+	}
+
+	project := CacheScope{Type: CacheScopeProject}
+	if project.volumeSuffix("/src/a") == project.volumeSuffix("/src/b") {
+		t.Errorf("Projects in different directories share the volume suffix %q", project.volumeSuffix("/src/a"))
+	}
+}
@@ -0,0 +1,263 @@
+package cmdrunner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	_rootHomeDir    = "/root"
+	_nonRootHomeDir = "/home/asb"
+
+	_volumeLabel      = "net.ashishb.asb.cache"
+	_volumeScopeLabel = "net.ashishb.asb.cache.scope"
+
+	// Image of the short-lived containers that prepare the cache volumes. It is fixed rather than
+	// the tool's image, which may have no shell, e.g. a "FROM scratch" one.
+	_helperImage = "busybox:1.37"
+)
+
+// cacheVolume is a named volume used to persist a tool's cache across runs
+type cacheVolume struct {
+	name   string // Volume name when running as root
+	target string // Mount point inside the container, "~/" is replaced with the home directory
+}
+
+// containerUser is the user the sandboxed process runs as
+type containerUser struct {
+	uid int
+	gid int
+}
+
+func (u containerUser) isRoot() bool {
+	return u.uid == 0
+}
+
+func (u containerUser) homeDir() string {
+	if u.isRoot() {
+		return _rootHomeDir
+	}
+	return _nonRootHomeDir
+}
+
+func (u containerUser) volumeTarget(volume cacheVolume) string {
+	if strings.HasPrefix(volume.target, "~/") {
+		return path.Join(u.homeDir(), strings.TrimPrefix(volume.target, "~/"))
+	}
+	return volume.target
+}
+
+func (c Config) getContainerUser() containerUser {
+	if !c.runAsNonRoot {
+		return containerUser{uid: 0, gid: 0}
+	}
+
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid < 0 || gid < 0 {
+		// E.g. on Windows
+		log.Debug().
+			Msg("Host user and group IDs are not available, running as root")
+		return containerUser{uid: 0, gid: 0}
+	}
+	return containerUser{uid: uid, gid: gid}
+}
+
+// getCacheVolumes returns the cache volumes to mount for this config
+func (c Config) getCacheVolumes() []cacheVolume {
+	if c.cmdType == CmdTypeImage {
+		return nil
+	}
+
+	volumes := make([]cacheVolume, 0, len(c.tool.Caches))
+	for _, name := range c.tool.Caches {
+		if volume, found := getCacheVolumeByName(name); found {
+			volumes = append(volumes, volume)
+		}
+	}
+	return volumes
+}
+
+// getVolumeName returns the name of the volume for this config.
+// Each cache scope gets its own volumes, and so does each non-root user, so that they are owned by them.
+func (c Config) getVolumeName(volume cacheVolume) string {
+	name := volume.name + c.cacheScope.volumeSuffix(c.workingDir)
+	if user := c.getContainerUser(); !user.isRoot() {
+		name += "-u" + strconv.Itoa(user.uid)
+	}
+	return name
+}
+
+func (c Config) getCacheVolumeMounts() []Mount {
+	mounts := c.getVolumeMounts(c.getCacheVolumes())
+	if !c.readOnlyCache {
+		return mounts
+	}
+
+	// The tool gets a throwaway copy of each cache volume, so that it cannot modify the cache
+	for i := range mounts {
+		if ephemeralName, ok := c.ephemeralVolumes[mounts[i].Source]; ok {
+			mounts[i].Source = ephemeralName
+		}
+		mounts[i].purpose = _mountPurposeEphemeralCache
+	}
+	return mounts
+}
+
+func (c Config) getVolumeMounts(volumes []cacheVolume) []Mount {
+	user := c.getContainerUser()
+	mounts := make([]Mount, 0, len(volumes))
+	for _, volume := range volumes {
+		mounts = append(mounts, Mount{
+			Type:    MountTypeVolume,
+			Source:  c.getVolumeName(volume),
+			Target:  user.volumeTarget(volume),
+			purpose: _mountPurposeCache,
+		})
+	}
+	return mounts
+}
+
+// prepareCacheVolumes creates the missing cache volumes with a label, so that "asb cache" can find them.
+// For a non-root user, it also makes them owned by that user, as volumes are created owned by root.
+func prepareCacheVolumes(ctx context.Context, backend Backend, config Config) error {
+	user := config.getContainerUser()
+	newVolumes := make([]cacheVolume, 0)
+	for _, volume := range config.getCacheVolumes() {
+		name := config.getVolumeName(volume)
+		exists, err := backend.VolumeExists(ctx, name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		labels := map[string]string{_volumeLabel: volume.name, _volumeScopeLabel: config.cacheScope.label(config.workingDir)}
+		if err = backend.CreateVolume(ctx, name, labels); err != nil {
+			return err
+		}
+		newVolumes = append(newVolumes, volume)
+	}
+
+	if len(newVolumes) == 0 || user.isRoot() {
+		return nil
+	}
+
+	if err := chownVolumes(ctx, backend, config, newVolumes); err != nil {
+		// Remove the volumes so that the next run retries
+		for _, volume := range newVolumes {
+			_ = backend.RemoveVolume(ctx, config.getVolumeName(volume))
+		}
+		return err
+	}
+	return nil
+}
+
+// chownVolumes runs a short-lived root container that hands the volumes over to the non-root user
+func chownVolumes(ctx context.Context, backend Backend, config Config, volumes []cacheVolume) error {
+	user := config.getContainerUser()
+	owner := fmt.Sprintf("%d:%d", user.uid, user.gid)
+	script := make([]string, 0, len(volumes))
+	for _, volume := range volumes {
+		script = append(script, fmt.Sprintf("chown -R %s %q", owner, user.volumeTarget(volume)))
+	}
+
+	log.Info().
+		Str("owner", owner).
+		Msg("Preparing cache volumes for non-root user")
+	if _, err := runHelperContainer(ctx, backend, config, script, config.getVolumeMounts(volumes)); err != nil {
+		return fmt.Errorf("failed to prepare cache volumes: %w", err)
+	}
+	return nil
+}
+
+// runHelperContainer runs the shell script as root in a short-lived container of the helper image,
+// with the hardened security profile unless SetInsecure is set, and no network. It returns the output.
+func runHelperContainer(ctx context.Context, backend Backend, config Config, script []string, mounts []Mount) (string, error) {
+	if err := ensureImage(ctx, backend, _helperImage); err != nil {
+		return "", err
+	}
+
+	var output bytes.Buffer
+	spec := ContainerSpec{
+		Image:      _helperImage,
+		Entrypoint: []string{"/bin/sh"},
+		Cmd:        []string{"-c", strings.Join(script, " && ")},
+		User:       "0:0",
+		Mounts:     mounts,
+		Network:    string(NetworkNone),
+		Stdout:     &output,
+		Stderr:     &output,
+	}
+
+	// Runs as root with the base capabilities only, i.e. enough to change the owner of the files
+	helperConfig := Config{insecure: config.insecure, readOnlyRootFS: true}
+	if err := helperConfig.addSecurityToSpec(&spec); err != nil {
+		return "", err
+	}
+
+	log.Debug().
+		Strs("script", script).
+		Msg("Running helper container")
+	state, err := backend.Run(ctx, spec)
+	if err == nil && state.ExitCode != 0 {
+		err = fmt.Errorf("exit code %d", state.ExitCode)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(output.String()))
+	}
+	return output.String(), nil
+}
+
+// getHomeMounts returns the home directory of a non-root user, a throwaway tmpfs owned by them,
+// so that nothing written to it, e.g. a ~/.bashrc, outlives the run. The directories between it
+// and the cache volumes mounted inside it are tmpfs as well, else they get created as root while mounting.
+// The home directory of root is already a tmpfs with SetReadOnlyRootFS.
+func (c Config) getHomeMounts() []Mount {
+	user := c.getContainerUser()
+	if user.isRoot() {
+		return nil
+	}
+
+	options := fmt.Sprintf("rw,exec,nosuid,nodev,uid=%d,gid=%d", user.uid, user.gid)
+	dirs := append([]string{user.homeDir()}, getHomeIntermediateDirs(user, c.getCacheVolumes())...)
+	mounts := make([]Mount, 0, len(dirs))
+	for _, dir := range dirs {
+		mounts = append(mounts, Mount{
+			Type:    MountTypeTmpfs,
+			Target:  dir,
+			Options: options,
+			purpose: _mountPurposeScratch,
+		})
+	}
+	return mounts
+}
+
+// getHomeIntermediateDirs returns the directories between the home directory and
+// the mount points of the cache volumes inside it
+func getHomeIntermediateDirs(user containerUser, volumes []cacheVolume) []string {
+	homeDir := user.homeDir()
+	dirs := make([]string, 0)
+	for _, volume := range volumes {
+		target := user.volumeTarget(volume)
+		if !strings.HasPrefix(target, homeDir+"/") {
+			continue
+		}
+
+		for dir := path.Dir(path.Clean(target)); dir != homeDir; dir = path.Dir(dir) {
+			if !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	slices.Sort(dirs)
+	return dirs
+}
@@ -0,0 +1,59 @@
+package cmdrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runWithCLI runs the container via the "run" command of the docker or podman binary
+func runWithCLI(ctx context.Context, binary string, spec ContainerSpec, extraArgs ...string) (int, error) {
+	args := spec.cliRunArgs(extraArgs...)
+	log.Debug().
+		Str("binary", binary).
+		Strs("args", args).
+		Msg("Running container")
+
+	//nolint:gosec  // User is deliberately executing a command
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Cancel = func() error {
+		// Killing the CLI does not stop the container, so kill the container first
+		killWithCLI(ctx, binary, spec.Name)
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = _killTimeout
+	cmd.Stdin = spec.Stdin
+	cmd.Stdout = spec.Stdout
+	cmd.Stderr = spec.Stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to run %s container: %w", binary, err)
+	}
+	return 0, nil
+}
+
+func killWithCLI(ctx context.Context, binary string, name string) {
+	if name == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), _killTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, binary, "kill", name).CombinedOutput()
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("container", name).
+			Str("output", string(output)).
+			Msg("Failed to kill container")
+	}
+}
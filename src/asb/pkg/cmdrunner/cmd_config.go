@@ -0,0 +1,233 @@
+package cmdrunner
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/ashishb/asb/src/asb/pkg/registry"
+)
+
+type Config struct {
+	backendType     BackendType       // Container backend to run the sandbox with
+	dockerBaseImage string            // Docker base image to use
+	imageDigests    map[string]string // Digests pinning the images, keyed by image, e.g. from the lock file
+
+	requestedToolVersion string      // Toolchain version requested by the user, overrides the project files
+	toolVersion          ToolVersion // Toolchain version whose image is used, set by selectToolchainImage
+	cmdType              CmdType
+	tool                 registry.Tool // Registry entry of cmdType
+	entrypoint           []string      // Overrides the image's entrypoint, empty keeps it
+	workingDir           string        // Working directory for the command
+	args                 []string      // Optional arguments to the command
+
+	// At most one of these should be true
+	mountWorkingDirRW bool // Whether to mount the working directory into the container as read-write
+	mountWorkingDirRO bool // Whether to mount the working directory into the container as read-only
+
+	mountReferencedDirRO bool // Whether to mount the referenced directory into the container as read-only
+	mountReferencedDirRW bool // Whether to mount the referenced directory into the container as read-write
+
+	mountWritablePaths bool     // Whether the writable paths of a read-only working directory are read-write
+	writablePaths      []string // Paths of the working directory that are read-write with mountWritablePaths
+
+	allowGitWrite bool     // Whether the .git directory of a read-write working directory is writable
+	readOnlyPaths []string // Paths of a read-write working directory that are mounted as read-only
+
+	referencedPaths       []referencedPath // Paths referenced by the args to mount, set by checkSensitiveMounts
+	sensitivePaths        []string         // Paths never to mount, in addition to the built-in ones
+	allowedSensitivePaths []string         // Built-in sensitive paths that can be mounted
+
+	runAsNonRoot bool        // Whether to run the container as non-root user
+	networkType  NetworkType // Network type for the container
+	loadDotEnv   bool        // Whether to load .env file from working directory
+
+	extraMounts []bindMount       // Additional bind mounts, e.g. from the config file
+	env         map[string]string // Additional environment variables to set inside the container
+
+	policy Policy // Hard limits this config must satisfy
+
+	allowedDomains     []string // Domains allowed in addition to the tool's defaults with NetworkEgressProxy
+	egressProxyAddress string   // Address of the running egress proxy, set by RunCmd
+
+	publishedPorts []PortMapping // Container ports to publish on the host, needs NetworkBridge
+
+	resourceLimits ResourceLimits // Limits on the resources available to the sandbox
+	containerName  string         // Name of the container, set by RunCmd
+
+	cacheScope       CacheScope        // Which projects share the cache volumes
+	readOnlyCache    bool              // Whether the tool gets throwaway copies of the cache volumes
+	ephemeralVolumes map[string]string // Cache volume name to its throwaway copy, set by RunCmd
+
+	insecure       bool // Whether to disable the hardened security profile
+	readOnlyRootFS bool // Whether to mount the container's root filesystem as read-only
+
+	auditLogFile string // File that a record of the run is appended to, empty means the default file
+}
+
+type bindMount struct {
+	source   string
+	target   string
+	readOnly bool
+}
+
+type Option func(*Config)
+
+func SetWorkingDir(workingDir string) Option {
+	return func(c *Config) {
+		c.workingDir = workingDir
+	}
+}
+
+func SetArgs(args []string) Option {
+	return func(c *Config) {
+		c.args = c.tool.Args(args)
+	}
+}
+
+func SetNetworkType(networkType NetworkType) Option {
+	return func(c *Config) {
+		c.networkType = networkType
+	}
+}
+
+func SetRunAsNonRoot(runAsNonRoot bool) Option {
+	return func(c *Config) {
+		c.runAsNonRoot = runAsNonRoot
+	}
+}
+
+func SetMountWorkingDirReadOnly(mountRO bool) Option {
+	return func(c *Config) {
+		if mountRO {
+			c.mountWorkingDirRW = false
+			c.mountReferencedDirRW = false
+		}
+		c.mountWorkingDirRO = mountRO
+		c.mountReferencedDirRO = mountRO
+		c.mountWritablePaths = false
+	}
+}
+
+func SetMountWorkingDirReadWrite(mountRW bool) Option {
+	return func(c *Config) {
+		if mountRW {
+			c.mountWorkingDirRO = false
+			c.mountReferencedDirRO = false
+		}
+		c.mountWorkingDirRW = mountRW
+		c.mountReferencedDirRW = mountRW
+		c.mountWritablePaths = false
+	}
+}
+
+func SetLoadDotEnv(loadDotEnv bool) Option {
+	return func(c *Config) {
+		c.loadDotEnv = loadDotEnv
+	}
+}
+
+// AddMount adds a bind mount of source (on the host) to target (inside the container)
+func AddMount(source string, target string, readOnly bool) Option {
+	return func(c *Config) {
+		c.extraMounts = append(c.extraMounts, bindMount{
+			source:   source,
+			target:   target,
+			readOnly: readOnly,
+		})
+	}
+}
+
+// SetEnv sets environment variables inside the container, merging with any set earlier
+func SetEnv(env map[string]string) Option {
+	return func(c *Config) {
+		if c.env == nil {
+			c.env = make(map[string]string, len(env))
+		}
+		maps.Copy(c.env, env)
+	}
+}
+
+// validate checks that the options set on this config are consistent with each other
+func (c Config) validate() error {
+	if c.dockerBaseImage == "" {
+		return fmt.Errorf("no image set for %s", c.cmdType)
+	}
+	if len(c.publishedPorts) > 0 && c.networkType != NetworkBridge {
+		return fmt.Errorf("publishing ports needs the %q network, but the network is %q",
+			NetworkBridge, c.networkType)
+	}
+	return nil
+}
+
+// NewConfig returns the config to run the tool of cmdType with, or ErrUnsupportedTool
+func NewConfig(cmdType CmdType, options ...Option) (Config, error) {
+	tool, found := getTool(cmdType)
+	if !found {
+		return Config{}, fmt.Errorf("%w %q", ErrUnsupportedTool, cmdType)
+	}
+
+	cfg := getDefaultConfig()
+	cfg.dockerBaseImage = tool.Image
+	cfg.cmdType = cmdType
+	cfg.tool = tool
+	resourceLimits, err := getToolResourceLimits(tool)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.resourceLimits = resourceLimits
+	for _, option := range slices.Concat(getToolDefaults(tool), options) {
+		option(&cfg)
+	}
+	return cfg, nil
+}
+
+// getToolResourceLimits returns the tool's default resource limits
+func getToolResourceLimits(tool registry.Tool) (ResourceLimits, error) {
+	limits := ResourceLimits{CPUs: tool.CPUs, PidsLimit: tool.PidsLimit, Timeout: tool.Timeout}
+	if tool.Memory != "" {
+		memoryBytes, err := ParseMemorySize(tool.Memory)
+		if err != nil {
+			return ResourceLimits{}, fmt.Errorf("invalid memory of tool %q: %w", tool.Name, err)
+		}
+		limits.MemoryBytes = memoryBytes
+	}
+	return limits, nil
+}
+
+// getToolDefaults returns the options for the tool's default network and disk access,
+// options set later override them
+func getToolDefaults(tool registry.Tool) []Option {
+	options := make([]Option, 0)
+	if tool.Network != "" {
+		options = append(options, SetNetworkType(NetworkType(tool.Network)))
+	}
+
+	switch tool.DiskAccess {
+	case registry.DiskAccessReadOnly:
+		options = append(options, SetMountWorkingDirReadOnly(true))
+	case registry.DiskAccessWritablePaths:
+		options = append(options, SetMountWorkingDirWritablePaths(true))
+	case registry.DiskAccessNone:
+		options = append(options, SetMountWorkingDirReadWrite(false))
+	default:
+		options = append(options, SetMountWorkingDirReadWrite(true))
+	}
+	return options
+}
+
+func getDefaultConfig() Config {
+	return Config{
+		workingDir:           ".",
+		args:                 nil,
+		mountWorkingDirRW:    true,
+		mountWorkingDirRO:    false,
+		mountReferencedDirRO: false,
+		mountReferencedDirRW: false,
+		runAsNonRoot:         true,
+		networkType:          NetworkHost,
+		backendType:          BackendAuto,
+		cacheScope:           CacheScope{Type: CacheScopeGlobal},
+		loadDotEnv:           false,
+	}
+}
@@ -0,0 +1,399 @@
+package cmdrunner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	isatty "github.com/mattn/go-isatty"
+)
+
+const (
+	_claudeConfigFileName = ".claude.json"
+
+	// Same exit code as the timeout(1) command
+	_timeoutExitCode = 124
+	_killTimeout     = 10 * time.Second
+)
+
+// Config directories of coding agents, relative to the home directory
+// Only the agents' own directories are mounted, never ~/.config as a whole, as it holds e.g. the gcloud
+// credentials and the asb user config.
+var _codingAgentConfigDirs = []string{
+	".claude",        // Anthropic Claude code config
+	".config/claude", // Anthropic Claude code config, in the XDG config directory
+	".codex",         // OpenAI Codex config
+	".gemini",        // Google Gemini CLI config
+}
+
+// Result describes a finished run of a command
+type Result struct {
+	ExitCode  int
+	StartTime time.Time     // When the run started
+	Duration  time.Duration // Time taken by the whole run, including pulling the image
+}
+
+// RunCmd runs the command inside the sandbox and waits for it to exit.
+// A non-zero exit code is returned as an *ExitError, along with the result.
+func RunCmd(ctx context.Context, config Config) (Result, error) {
+	result := Result{StartTime: time.Now()}
+	exitCode, err := runCmd(ctx, config)
+	result.Duration = time.Since(result.StartTime)
+	if err != nil {
+		return result, fmt.Errorf("failed to run %s command: %w", config.cmdType, err)
+	}
+
+	result.ExitCode = exitCode
+	if exitCode != 0 {
+		return result, &ExitError{Code: exitCode}
+	}
+	return result, nil
+}
+
+// runCmd runs the command and returns its exit code, everything it sets up is cleaned up before returning
+func runCmd(ctx context.Context, config Config) (exitCode int, err error) {
+	defer func() {
+		// A run that ends before its container is created is recorded too, e.g. one refused by the policy
+		if err != nil && config.containerName == "" {
+			recordAuditFailure(config, err)
+		}
+	}()
+
+	config, err = config.selectToolchainImage()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := config.validate(); err != nil {
+		return 0, err
+	}
+
+	if config, err = config.checkSensitiveMounts(); err != nil {
+		return 0, err
+	}
+
+	if err := config.checkPolicy(); err != nil {
+		return 0, err
+	}
+
+	// 1. Check that the container backend is installed and running
+	backend, err := NewBackend(ctx, config.backendType)
+	if err != nil {
+		return 0, err
+	}
+
+	config.resourceLimits = config.resourceLimits.capCPUs(ctx, backend)
+
+	// Download the docker image
+	if err = ensureImage(ctx, backend, config.getImage()); err != nil {
+		if config.toolVersion.Selected != "" {
+			return 0, fmt.Errorf("no %s image for version %s, requested by %s: %w",
+				config.cmdType, config.toolVersion.Selected, config.toolVersion.Source, err)
+		}
+		return 0, err
+	}
+
+	if digest := config.imageDigests[config.dockerBaseImage]; digest != "" {
+		if err = verifyImageDigest(ctx, backend, config.getImage(), digest); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = prepareCacheVolumes(ctx, backend, config); err != nil {
+		return 0, err
+	}
+	recordCacheUsage(config)
+
+	if config.readOnlyCache {
+		ephemeralVolumes, removeEphemeralVolumes, err := createEphemeralCacheVolumes(ctx, backend, config)
+		if err != nil {
+			return 0, err
+		}
+		defer removeEphemeralVolumes()
+		config.ephemeralVolumes = ephemeralVolumes
+	}
+
+	if config.networkType == NetworkEgressProxy {
+		proxy, address, err := startEgressProxy(ctx, backend, config)
+		if err != nil {
+			return 0, err
+		}
+		defer func() { _ = proxy.Close() }()
+		config.egressProxyAddress = address
+	}
+
+	if err = setupDirMappingsForCodingAgents(config); err != nil {
+		return 0, err
+	}
+
+	if err = createWritablePaths(config); err != nil {
+		return 0, err
+	}
+	defer removeMountPointFiles(config.getMissingAsbConfigFiles())
+
+	// Now run the image with the config
+	config.containerName = newContainerName(config.cmdType)
+	return runContainer(ctx, backend, config)
+}
+
+func runContainer(ctx context.Context, backend Backend, config Config) (int, error) {
+	spec, err := getContainerSpec(config)
+	if err != nil {
+		recordAuditFailure(config, err)
+		return 0, err
+	}
+
+	if config.resourceLimits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.resourceLimits.Timeout)
+		defer cancel()
+	}
+
+	// Recorded before the run as well, so that a run is recorded even if asb gets killed
+	startTime := time.Now()
+	record := newAuditRecord(ctx, backend, config, spec, startTime)
+	record.Status = AuditStatusStarted
+	recordAudit(config, record)
+
+	// Note: This is a blocking call
+	state, err := backend.Run(ctx, spec)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Error().
+			Dur("timeout", config.resourceLimits.Timeout).
+			Msg("Sandbox was killed after reaching the timeout")
+		state, err = ExitState{ExitCode: _timeoutExitCode}, nil
+	}
+	exitCode := state.ExitCode
+
+	record.DurationSeconds = time.Since(startTime).Seconds()
+	if err != nil {
+		record.Status = AuditStatusFailed
+		record.Error = err.Error()
+		recordAudit(config, record)
+		return 0, err
+	}
+	record.Status = AuditStatusExited
+	record.ExitCode = exitCode
+	recordAudit(config, record)
+
+	if reason := config.resourceLimits.describeKill(state); reason != "" {
+		log.Error().
+			Int("exitCode", exitCode).
+			Msg(reason)
+	}
+
+	log.Debug().
+		Str("backend", backend.Name()).
+		Str("container", spec.Name).
+		Int("exitCode", exitCode).
+		Msg("Container exited")
+	return exitCode, nil
+}
+
+func getContainerSpec(config Config) (ContainerSpec, error) {
+	spec := ContainerSpec{
+		Name:       config.containerName,
+		Image:      config.getImage(),
+		Entrypoint: config.entrypoint,
+		Cmd:        config.args,
+		WorkingDir: config.workingDir,
+		Init:       true,
+		Network:    string(config.networkType),
+		Publish:    config.publishedPorts,
+		Resources:  config.resourceLimits,
+	}
+
+	// Like "docker run --interactive", stdin is always passed through, so that input can be piped in.
+	// A TTY is only allocated when all the streams are terminals, as a TTY merges stderr into stdout
+	// and rewrites line endings, which would corrupt redirected output.
+	spec.Interactive = true
+	spec.TTY = isInteractiveTerminal()
+	spec.Stdin = os.Stdin
+	spec.Stdout = os.Stdout
+	spec.Stderr = os.Stderr
+
+	if err := config.addSecurityToSpec(&spec); err != nil {
+		return ContainerSpec{}, err
+	}
+
+	if config.mountWorkingDirRW || config.mountWorkingDirRO {
+		spec.Mounts = append(spec.Mounts, Mount{
+			Type:     MountTypeBind,
+			Source:   config.workingDir,
+			Target:   config.workingDir,
+			ReadOnly: !config.mountWorkingDirRW,
+			purpose:  _mountPurposeWorkingDir,
+		})
+
+		writablePathMounts, err := config.getWritablePathMounts()
+		if err != nil {
+			return ContainerSpec{}, err
+		}
+		spec.Mounts = append(spec.Mounts, writablePathMounts...)
+
+		readOnlyPathMounts, err := config.getReadOnlyPathMounts()
+		if err != nil {
+			return ContainerSpec{}, err
+		}
+		spec.Mounts = append(spec.Mounts, readOnlyPathMounts...)
+
+		maskedPathMounts, err := config.getMaskedPathMounts()
+		if err != nil {
+			return ContainerSpec{}, err
+		}
+		spec.Mounts = append(spec.Mounts, maskedPathMounts...)
+	}
+
+	if config.mountReferencedDirRW || config.mountReferencedDirRO {
+		for _, referenced := range config.referencedPaths {
+			spec.Mounts = append(spec.Mounts, Mount{
+				Type:     MountTypeBind,
+				Source:   referenced.source,
+				Target:   referenced.target,
+				ReadOnly: !config.mountReferencedDirRW,
+				purpose:  _mountPurposeReferenced,
+			})
+		}
+	}
+
+	for _, mount := range config.extraMounts {
+		spec.Mounts = append(spec.Mounts, Mount{
+			Type:     MountTypeBind,
+			Source:   mount.source,
+			Target:   mount.target,
+			ReadOnly: mount.readOnly,
+			purpose:  _mountPurposeConfig,
+		})
+	}
+
+	if config.loadDotEnv {
+		spec.EnvFiles = append(spec.EnvFiles, filepath.Join(config.workingDir, ".env"))
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(config.env)) {
+		spec.Env = append(spec.Env, fmt.Sprintf("%s=%s", key, config.env[key]))
+	}
+
+	agentMounts, err := getCodingAgentMounts(config)
+	if err != nil {
+		return ContainerSpec{}, err
+	}
+
+	// The home directory goes first, as the other mounts may be inside it
+	spec.Mounts = append(spec.Mounts, config.getHomeMounts()...)
+	spec.Mounts = append(spec.Mounts, agentMounts...)
+	spec.Mounts = append(spec.Mounts, config.getCacheVolumeMounts()...)
+
+	user := config.getContainerUser()
+	if !user.isRoot() {
+		spec.User = fmt.Sprintf("%d:%d", user.uid, user.gid)
+		spec.Env = append(spec.Env, "HOME="+user.homeDir())
+	}
+
+	if config.networkType == NetworkEgressProxy {
+		spec.Network = _egressNetworkName
+		spec.Env = append(spec.Env, getEgressProxyEnv(config.egressProxyAddress)...)
+	}
+	return spec, nil
+}
+
+// setupDirMappingsForCodingAgents creates the config files and directories of the coding agents
+// on the host, else they get created as directories owned by root while mounting
+func setupDirMappingsForCodingAgents(config Config) error {
+	mounts, err := getCodingAgentMounts(config)
+	if err != nil {
+		return err
+	}
+
+	for _, mount := range mounts {
+		if path.Base(mount.Target) == _claudeConfigFileName {
+			if err = touchFile(mount.Source); err != nil {
+				return fmt.Errorf("failed to touch %s: %w", mount.Source, err)
+			}
+			continue
+		}
+
+		if err = os.MkdirAll(mount.Source, 0o700); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", mount.Source, err)
+		}
+	}
+	return nil
+}
+
+func getCodingAgentMounts(config Config) ([]Mount, error) {
+	if !config.tool.MountAgentConfigs {
+		return make([]Mount, 0), nil
+	}
+
+	if !config.mountReferencedDirRW && !config.mountReferencedDirRO {
+		log.Debug().
+			Msg("No disk access enabled inside the sandbox, skipping directory mappings for coding agents")
+		return make([]Mount, 0), nil
+	}
+
+	mounts := make([]Mount, 0)
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	// ~/.claude.json mapped to ~/.claude.json (inside Docker)
+	containerHomeDir := config.getContainerUser().homeDir()
+	mounts = append(mounts, Mount{
+		Type:     MountTypeBind,
+		Source:   filepath.Join(homeDir, _claudeConfigFileName),
+		Target:   path.Join(containerHomeDir, _claudeConfigFileName),
+		ReadOnly: config.mountReferencedDirRO,
+		purpose:  _mountPurposeAgentConfig,
+	})
+
+	for _, dirName := range _codingAgentConfigDirs {
+		mounts = append(mounts, Mount{
+			Type:     MountTypeBind,
+			Source:   filepath.Join(homeDir, dirName),
+			Target:   path.Join(containerHomeDir, dirName),
+			ReadOnly: config.mountReferencedDirRO,
+			purpose:  _mountPurposeAgentConfig,
+		})
+	}
+	return mounts, nil
+}
+
+// newContainerName returns a unique name, so that the container can be found again, e.g. to kill it
+func newContainerName(cmdType CmdType) string {
+	suffix := make([]byte, 6)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("asb-%s-%s", strings.ReplaceAll(string(cmdType), "_", "-"), hex.EncodeToString(suffix))
+}
+
+// isInteractiveTerminal returns whether stdin, stdout and stderr are all terminals
+func isInteractiveTerminal() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) &&
+		isatty.IsTerminal(os.Stdout.Fd()) &&
+		isatty.IsTerminal(os.Stderr.Fd())
+}
+
+// touchFile mimics the basic behavior of the Unix 'touch' command
+func touchFile(name string) error {
+	file, err := os.OpenFile(name, os.O_RDONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to touch file %s: %w", name, err)
+	}
+
+	log.Debug().
+		Str("file", name).
+		Msg("Created file")
+	// It's crucial to close the file to release the file descriptor
+	return file.Close()
+}
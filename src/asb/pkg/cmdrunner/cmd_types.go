@@ -0,0 +1,53 @@
+package cmdrunner
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ashishb/asb/src/asb/pkg/registry"
+)
+
+type (
+	CmdType     string
+	NetworkType string
+)
+
+// Tools that can be run, keyed by command type. Replaced by SetToolRegistry
+// once the user's additions are loaded.
+var _toolRegistry = registry.Default()
+
+// SetToolRegistry sets the registry of the tools that can be run
+func SetToolRegistry(toolRegistry *registry.Registry) {
+	_toolRegistry = toolRegistry
+}
+
+// Ref: https://docs.docker.com/engine/network/
+const (
+	NetworkHost   NetworkType = "host"
+	NetworkNone   NetworkType = "none"
+	NetworkBridge NetworkType = "bridge"
+
+	// NetworkEgressProxy puts the container on an internal network whose only way out is
+	// an HTTP(S) proxy run by asb that only allows the tool's package registries
+	NetworkEgressProxy NetworkType = "proxy"
+)
+
+// Network types that can be selected by the user
+var _userNetworkTypes = []NetworkType{NetworkHost, NetworkNone, NetworkBridge, NetworkEgressProxy}
+
+// ParseNetworkType parses a user provided network type
+func ParseNetworkType(value string) (NetworkType, error) {
+	networkType := NetworkType(value)
+	if !slices.Contains(_userNetworkTypes, networkType) {
+		return "", fmt.Errorf("unsupported network type %q, supported types are %q", value, _userNetworkTypes)
+	}
+	return networkType, nil
+}
+
+// ParseCmdType parses a user provided command type, e.g. "npm" or "python_uvx"
+func ParseCmdType(value string) (CmdType, error) {
+	if _, found := _toolRegistry.Tool(value); !found {
+		return "", fmt.Errorf("%w %q, supported tools are %q", ErrUnsupportedTool, value, _toolRegistry.ToolNames())
+	}
+	return CmdType(value), nil
+}
@@ -0,0 +1,178 @@
+package cmdrunner
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type MountType string
+
+const (
+	MountTypeBind   MountType = "bind"
+	MountTypeVolume MountType = "volume"
+	MountTypeTmpfs  MountType = "tmpfs"
+)
+
+// Why a mount exists, shown by Explain
+const (
+	_mountPurposeWorkingDir     = "working directory"
+	_mountPurposeReadOnlyPath   = "read-only path"
+	_mountPurposeWritablePath   = "writable path"
+	_mountPurposeMasked         = "masked by " + _asbIgnoreFileName
+	_mountPurposeReferenced     = "referenced file"
+	_mountPurposeConfig         = "config file"
+	_mountPurposeAgentConfig    = "coding agent config"
+	_mountPurposeCache          = "cache volume"
+	_mountPurposeEphemeralCache = "ephemeral copy of cache volume"
+	_mountPurposeScratch        = "scratch space"
+)
+
+// Mount is a filesystem mounted inside the container
+type Mount struct {
+	Type     MountType
+	Source   string // Host path for bind mounts, volume name for volume mounts, empty for tmpfs
+	Target   string // Path inside the container
+	ReadOnly bool
+	Options  string // Mount options, only used for tmpfs, e.g. "rw,exec,nosuid"
+
+	purpose string
+}
+
+// ContainerSpec is a backend-independent description of the sandbox container
+type ContainerSpec struct {
+	Name       string
+	Image      string
+	Entrypoint []string // Empty means the image's entrypoint
+	Cmd        []string
+	WorkingDir string
+	User       string // "uid:gid", empty means the image's user
+
+	Env      []string // KEY=VALUE pairs
+	EnvFiles []string // Host paths of env files
+	Mounts   []Mount
+
+	Network string
+	Publish []PortMapping
+
+	Init           bool
+	Interactive    bool // Keep stdin open
+	TTY            bool // Allocate a pseudo-TTY
+	ReadOnlyRootFS bool
+	CapDrop        []string
+	CapAdd         []string
+	SecurityOpts   []string
+	Resources      ResourceLimits
+
+	// Streams to attach to the container, nil means not attached
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// cliRunArgs returns the arguments of "docker run" (or the compatible "podman run")
+// for this spec, excluding the binary name
+func (s ContainerSpec) cliRunArgs(extraArgs ...string) []string {
+	args := []string{"run", "--rm"}
+	if s.Init {
+		args = append(args, "--init")
+	}
+	if s.Interactive {
+		args = append(args, "--interactive")
+	}
+	if s.TTY {
+		args = append(args, "--tty")
+	}
+	if s.Name != "" {
+		args = append(args, "--name="+s.Name)
+	}
+
+	args = append(args, extraArgs...)
+	args = append(args, s.Resources.cliArgs()...)
+	args = append(args, s.securityCLIArgs()...)
+	for _, mount := range s.Mounts {
+		args = append(args, mount.cliArg())
+	}
+	for _, envFile := range s.EnvFiles {
+		args = append(args, "--env-file="+envFile)
+	}
+	for _, env := range s.Env {
+		args = append(args, "--env="+env)
+	}
+	if s.User != "" {
+		args = append(args, "--user="+s.User)
+	}
+	for _, mapping := range s.Publish {
+		args = append(args, "--publish="+mapping.String())
+	}
+	if s.Network != "" {
+		args = append(args, "--network="+s.Network)
+	}
+	if s.WorkingDir != "" {
+		args = append(args, "--workdir="+s.WorkingDir)
+	}
+	if len(s.Entrypoint) > 0 {
+		// CLI only supports the first element as the entrypoint, pass the rest as args
+		args = append(args, "--entrypoint="+s.Entrypoint[0])
+		args = append(args, s.Image)
+		args = append(args, s.Entrypoint[1:]...)
+		return append(args, s.Cmd...)
+	}
+
+	args = append(args, s.Image)
+	return append(args, s.Cmd...)
+}
+
+func (s ContainerSpec) securityCLIArgs() []string {
+	args := make([]string, 0)
+	if s.ReadOnlyRootFS {
+		args = append(args, "--read-only")
+	}
+	for _, capability := range s.CapDrop {
+		args = append(args, "--cap-drop="+capability)
+	}
+	for _, capability := range s.CapAdd {
+		args = append(args, "--cap-add="+capability)
+	}
+	for _, securityOpt := range s.SecurityOpts {
+		args = append(args, "--security-opt="+securityOpt)
+	}
+	return args
+}
+
+func (m Mount) cliArg() string {
+	switch m.Type {
+	case MountTypeTmpfs:
+		if m.Options == "" {
+			return "--tmpfs=" + m.Target
+		}
+		return fmt.Sprintf("--tmpfs=%s:%s", m.Target, m.Options)
+	case MountTypeVolume:
+		arg := fmt.Sprintf("--mount=type=volume,src=%s,target=%s", m.Source, m.Target)
+		if m.ReadOnly {
+			arg += ",readonly"
+		}
+		return arg
+	default:
+		arg := fmt.Sprintf("--mount=type=bind,source=%s,target=%s", m.Source, m.Target)
+		if m.ReadOnly {
+			arg += ",readonly"
+		}
+		return arg
+	}
+}
+
+func (l ResourceLimits) cliArgs() []string {
+	args := make([]string, 0)
+	if l.MemoryBytes > 0 {
+		memory := strconv.FormatInt(l.MemoryBytes, 10)
+		args = append(args, "--memory="+memory, "--memory-swap="+memory)
+	}
+	if l.CPUs > 0 {
+		args = append(args, "--cpus="+strconv.FormatFloat(l.CPUs, 'f', -1, 64))
+	}
+	if l.PidsLimit > 0 {
+		args = append(args, "--pids-limit="+strconv.FormatInt(l.PidsLimit, 10))
+	}
+	return args
+}
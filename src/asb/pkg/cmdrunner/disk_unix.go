@@ -0,0 +1,18 @@
+//go:build !windows
+
+package cmdrunner
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// getFreeDiskBytes returns the disk space available to unprivileged users on the filesystem of dir
+func getFreeDiskBytes(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("failed to get free disk space of %s: %w", dir, err)
+	}
+	//nolint:gosec,unconvert  // Field types differ across platforms, none of them is negative
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
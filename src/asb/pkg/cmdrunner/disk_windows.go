@@ -0,0 +1,12 @@
+//go:build windows
+
+package cmdrunner
+
+import (
+	"errors"
+)
+
+// getFreeDiskBytes is not supported, as the backend's volumes live inside a VM on Windows
+func getFreeDiskBytes(string) (uint64, error) {
+	return 0, errors.New("checking free disk space is not supported on Windows")
+}
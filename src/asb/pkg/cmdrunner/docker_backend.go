@@ -0,0 +1,157 @@
+package cmdrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// dockerBackend talks to the Docker daemon via its API, so the docker CLI is not needed
+type dockerBackend struct {
+	client *docker.Client
+}
+
+var _ Backend = dockerBackend{}
+
+func newDockerBackend() (dockerBackend, error) {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return dockerBackend{}, fmt.Errorf("docker is not installed: %w", err)
+	}
+	return dockerBackend{client: client}, nil
+}
+
+func (b dockerBackend) Name() string {
+	return string(BackendDocker)
+}
+
+func (b dockerBackend) Ping(ctx context.Context) error {
+	if err := b.client.PingWithContext(ctx); err != nil {
+		return fmt.Errorf("docker is not running: %w", err)
+	}
+	return nil
+}
+
+func (b dockerBackend) Info(_ context.Context) (BackendInfo, error) {
+	info, err := b.client.Info()
+	if err != nil {
+		return BackendInfo{}, fmt.Errorf("failed to get docker info: %w", err)
+	}
+	return BackendInfo{
+		Version:     info.ServerVersion,
+		Rootless:    slices.Contains(info.SecurityOptions, "name=rootless"),
+		UsernsRemap: slices.Contains(info.SecurityOptions, "name=userns"),
+		VolumeDir:   filepath.Join(info.DockerRootDir, "volumes"),
+		CPUs:        info.NCPU,
+	}, nil
+}
+
+func (b dockerBackend) ImageExists(_ context.Context, image string) (bool, error) {
+	_, err := b.client.InspectImage(image)
+	if errors.Is(err, docker.ErrNoSuchImage) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect docker image %s: %w", image, err)
+	}
+	return true, nil
+}
+
+func (b dockerBackend) PullImage(ctx context.Context, image string) error {
+	pullOpts := docker.PullImageOptions{
+		Context:      ctx,
+		Repository:   image,
+		OutputStream: os.Stdout,
+	}
+	authOpts := docker.AuthConfiguration{}
+	return b.client.PullImage(pullOpts, authOpts)
+}
+
+func (b dockerBackend) ResolveImageDigest(_ context.Context, image string) (string, error) {
+	distribution, err := b.client.InspectDistribution(image)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve digest of docker image %s: %w", image, err)
+	}
+	return distribution.Descriptor.Digest.String(), nil
+}
+
+func (b dockerBackend) ImageDigests(_ context.Context, image string) ([]string, error) {
+	imageInfo, err := b.client.InspectImage(image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect docker image %s: %w", image, err)
+	}
+	return getRepoDigests(imageInfo.RepoDigests), nil
+}
+
+func (b dockerBackend) ListVolumes(ctx context.Context) ([]VolumeInfo, error) {
+	volumes, err := b.client.ListVolumes(docker.ListVolumesOptions{Context: ctx})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	result := make([]VolumeInfo, 0, len(volumes))
+	for _, volume := range volumes {
+		result = append(result, VolumeInfo{Name: volume.Name, Labels: volume.Labels})
+	}
+	return result, nil
+}
+
+func (b dockerBackend) VolumeExists(_ context.Context, name string) (bool, error) {
+	_, err := b.client.InspectVolume(name)
+	if errors.Is(err, docker.ErrNoSuchVolume) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect volume %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func (b dockerBackend) CreateVolume(ctx context.Context, name string, labels map[string]string) error {
+	_, err := b.client.CreateVolume(docker.CreateVolumeOptions{
+		Context: ctx,
+		Name:    name,
+		Labels:  labels,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create volume %s: %w", name, err)
+	}
+	return nil
+}
+
+func (b dockerBackend) RemoveVolume(ctx context.Context, name string) error {
+	err := b.client.RemoveVolumeWithOptions(docker.RemoveVolumeOptions{Context: ctx, Name: name})
+	if err != nil {
+		return fmt.Errorf("failed to remove volume %s: %w", name, err)
+	}
+	return nil
+}
+
+func (b dockerBackend) EnsureNetwork(ctx context.Context, name string, internal bool) (string, error) {
+	network, err := b.client.NetworkInfo(name)
+	var noSuchNetworkErr *docker.NoSuchNetwork
+	if errors.As(err, &noSuchNetworkErr) {
+		network, err = b.client.CreateNetwork(docker.CreateNetworkOptions{
+			Context:  ctx,
+			Name:     name,
+			Driver:   "bridge",
+			Internal: internal,
+		})
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to set up network %s: %w", name, err)
+	}
+
+	for _, ipamConfig := range network.IPAM.Config {
+		if ip := net.ParseIP(ipamConfig.Gateway); ip != nil && ip.To4() != nil {
+			return ipamConfig.Gateway, nil
+		}
+	}
+	return "", fmt.Errorf("network %s has no IPv4 gateway", name)
+}
@@ -0,0 +1,436 @@
+package cmdrunner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/moby/term"
+	"github.com/rs/zerolog/log"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// Label set on all the containers created by asb
+const _containerLabel = "net.ashishb.asb"
+
+// Run creates the container, attaches to it, starts it and waits for it to exit.
+// The container is removed afterward, even if ctx gets cancelled in between.
+func (b dockerBackend) Run(ctx context.Context, spec ContainerSpec) (ExitState, error) {
+	createOpts, err := getCreateContainerOptions(ctx, spec)
+	if err != nil {
+		return ExitState{}, err
+	}
+
+	container, err := b.client.CreateContainer(createOpts)
+	if err != nil {
+		return ExitState{}, fmt.Errorf("failed to create container: %w", err)
+	}
+	defer b.removeContainer(ctx, container.ID)
+
+	attachment, err := b.attachContainer(container.ID, spec)
+	if err != nil {
+		return ExitState{}, err
+	}
+	defer func() { _ = attachment.Close() }()
+
+	if spec.TTY {
+		restoreTerminal, err := setRawTerminal(spec.Stdin)
+		if err != nil {
+			return ExitState{}, err
+		}
+		defer restoreTerminal()
+	}
+
+	if err = b.client.StartContainerWithContext(container.ID, nil, ctx); err != nil {
+		return ExitState{}, fmt.Errorf("failed to start container: %w", err)
+	}
+
+	log.Debug().
+		Str("container", spec.Name).
+		Str("id", container.ID).
+		Msg("Container started")
+
+	stopMonitoringPids := b.monitorPids(ctx, container.ID)
+	stopForwarding := b.forwardSignals(ctx, container.ID)
+	defer stopForwarding()
+	if spec.TTY {
+		stopResizing := b.monitorTTYSize(ctx, container.ID, spec.Stdout)
+		defer stopResizing()
+	}
+
+	exitCode, err := b.client.WaitContainerWithContext(container.ID, ctx)
+	peakPids := stopMonitoringPids()
+	if ctx.Err() != nil {
+		b.killContainer(ctx, container.ID)
+		return ExitState{}, fmt.Errorf("container was killed: %w", ctx.Err())
+	}
+	if err != nil {
+		return ExitState{}, fmt.Errorf("failed to wait for container: %w", err)
+	}
+
+	// Let the remaining output of the container get copied
+	waitForAttachment(attachment)
+	return ExitState{ExitCode: exitCode, OOMKilled: b.isOOMKilled(ctx, container.ID), PeakPids: peakPids}, nil
+}
+
+func getCreateContainerOptions(ctx context.Context, spec ContainerSpec) (docker.CreateContainerOptions, error) {
+	env, err := readEnvFiles(spec.EnvFiles)
+	if err != nil {
+		return docker.CreateContainerOptions{}, err
+	}
+
+	securityOpts, err := inlineSeccompProfile(spec.SecurityOpts)
+	if err != nil {
+		return docker.CreateContainerOptions{}, err
+	}
+
+	config := &docker.Config{
+		Image:        spec.Image,
+		Entrypoint:   spec.Entrypoint,
+		Cmd:          spec.Cmd,
+		WorkingDir:   spec.WorkingDir,
+		User:         spec.User,
+		Env:          append(env, spec.Env...),
+		Labels:       map[string]string{_containerLabel: "true"},
+		Tty:          spec.TTY,
+		OpenStdin:    spec.Interactive,
+		StdinOnce:    spec.Interactive,
+		AttachStdin:  spec.Stdin != nil,
+		AttachStdout: spec.Stdout != nil,
+		AttachStderr: spec.Stderr != nil,
+		ExposedPorts: make(map[docker.Port]struct{}),
+	}
+
+	hostConfig := &docker.HostConfig{
+		Init:           spec.Init,
+		NetworkMode:    spec.Network,
+		PortBindings:   make(map[docker.Port][]docker.PortBinding),
+		ReadonlyRootfs: spec.ReadOnlyRootFS,
+		CapDrop:        spec.CapDrop,
+		CapAdd:         spec.CapAdd,
+		SecurityOpt:    securityOpts,
+		Tmpfs:          make(map[string]string),
+		Memory:         spec.Resources.MemoryBytes,
+		MemorySwap:     spec.Resources.MemoryBytes,
+		NanoCPUs:       int64(spec.Resources.CPUs * 1e9),
+	}
+	if spec.Resources.PidsLimit > 0 {
+		hostConfig.PidsLimit = &spec.Resources.PidsLimit
+	}
+
+	for _, mount := range spec.Mounts {
+		if mount.Type == MountTypeTmpfs {
+			hostConfig.Tmpfs[mount.Target] = mount.Options
+			continue
+		}
+
+		hostConfig.Mounts = append(hostConfig.Mounts, docker.HostMount{
+			Type:     string(mount.Type),
+			Source:   mount.Source,
+			Target:   mount.Target,
+			ReadOnly: mount.ReadOnly,
+		})
+	}
+
+	for _, mapping := range spec.Publish {
+		port := docker.Port(fmt.Sprintf("%d/%s", mapping.ContainerPort, mapping.Protocol))
+		config.ExposedPorts[port] = struct{}{}
+		hostConfig.PortBindings[port] = append(hostConfig.PortBindings[port], docker.PortBinding{
+			HostIP:   mapping.HostIP,
+			HostPort: strconv.Itoa(mapping.HostPort),
+		})
+	}
+
+	return docker.CreateContainerOptions{
+		Context:    ctx,
+		Name:       spec.Name,
+		Config:     config,
+		HostConfig: hostConfig,
+	}, nil
+}
+
+// attachContainer attaches the streams of the spec to the container, this has to be done
+// before starting it so that no output gets lost
+func (b dockerBackend) attachContainer(id string, spec ContainerSpec) (docker.CloseWaiter, error) {
+	success := make(chan struct{})
+	attachment, err := b.client.AttachToContainerNonBlocking(docker.AttachToContainerOptions{
+		Container:    id,
+		InputStream:  spec.Stdin,
+		OutputStream: spec.Stdout,
+		ErrorStream:  spec.Stderr,
+		Success:      success,
+		RawTerminal:  spec.TTY,
+		Stream:       true,
+		Stdin:        spec.Stdin != nil,
+		Stdout:       spec.Stdout != nil,
+		Stderr:       spec.Stderr != nil,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to container: %w", err)
+	}
+
+	// Handshake with the client, it blocks till the attachment is acknowledged
+	<-success
+	success <- struct{}{}
+	return attachment, nil
+}
+
+func waitForAttachment(attachment docker.CloseWaiter) {
+	done := make(chan error, 1)
+	go func() { done <- attachment.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Msg("Attachment to the container ended with an error")
+		}
+	case <-time.After(_killTimeout):
+		log.Debug().
+			Msg("Timed out waiting for the remaining output of the container")
+	}
+}
+
+// monitorPids follows the number of processes and threads of the container while it runs,
+// and returns a function that stops following it and returns the highest number seen
+func (b dockerBackend) monitorPids(ctx context.Context, id string) func() int64 {
+	ctx, cancel := context.WithCancel(ctx)
+	stats := make(chan *docker.Stats)
+	done := make(chan bool)
+	go func() {
+		err := b.client.Stats(docker.StatsOptions{ID: id, Stats: stats, Stream: true, Done: done, Context: ctx})
+		if err != nil && ctx.Err() == nil {
+			log.Debug().
+				Err(err).
+				Str("id", id).
+				Msg("Failed to get container stats")
+		}
+	}()
+
+	peakPids := make(chan int64, 1)
+	go func() {
+		peak := int64(0)
+		// Stats closes the channel once it returns
+		for stat := range stats {
+			peak = max(peak, int64(stat.PidsStats.Current))
+		}
+		peakPids <- peak
+	}()
+
+	return func() int64 {
+		close(done)
+		cancel()
+		return <-peakPids
+	}
+}
+
+// isOOMKilled returns whether the kernel killed the exited container for exceeding the memory limit,
+// nil if it cannot be inspected
+func (b dockerBackend) isOOMKilled(ctx context.Context, id string) *bool {
+	container, err := b.client.InspectContainerWithOptions(docker.InspectContainerOptions{
+		ID:      id,
+		Context: context.WithoutCancel(ctx),
+	})
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("id", id).
+			Msg("Failed to inspect exited container")
+		return nil
+	}
+	return &container.State.OOMKilled
+}
+
+// forwardSignals sends the signals received by asb to the container, the way "docker run" does
+func (b dockerBackend) forwardSignals(ctx context.Context, id string) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, _forwardedSignals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				sysSignal, ok := sig.(syscall.Signal)
+				if !ok {
+					continue
+				}
+
+				log.Debug().
+					Str("signal", sig.String()).
+					Msg("Forwarding signal to the container")
+				err := b.client.KillContainer(docker.KillContainerOptions{
+					ID:      id,
+					Signal:  docker.Signal(sysSignal),
+					Context: ctx,
+				})
+				if err != nil {
+					log.Debug().
+						Err(err).
+						Str("signal", sig.String()).
+						Msg("Failed to forward signal to the container")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// monitorTTYSize keeps the size of the container's TTY in sync with the host terminal
+func (b dockerBackend) monitorTTYSize(ctx context.Context, id string, output any) func() {
+	fd, isTerminal := term.GetFdInfo(output)
+	if !isTerminal {
+		return func() {}
+	}
+
+	resize := func() {
+		size, err := term.GetWinsize(fd)
+		if err != nil || size.Height == 0 || size.Width == 0 {
+			return
+		}
+
+		if err = b.client.ResizeContainerTTY(id, int(size.Height), int(size.Width)); err != nil {
+			log.Debug().
+				Err(err).
+				Msg("Failed to resize container TTY")
+		}
+	}
+	resize()
+
+	resizes := make(chan os.Signal, 1)
+	notifyResize(resizes)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-resizes:
+				resize()
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(resizes)
+		close(done)
+	}
+}
+
+func (b dockerBackend) killContainer(ctx context.Context, id string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), _killTimeout)
+	defer cancel()
+	err := b.client.KillContainer(docker.KillContainerOptions{ID: id, Signal: docker.SIGKILL, Context: ctx})
+	var noSuchContainerErr *docker.NoSuchContainer
+	if err != nil && !errors.As(err, &noSuchContainerErr) {
+		log.Debug().
+			Err(err).
+			Str("id", id).
+			Msg("Failed to kill container")
+	}
+}
+
+// removeContainer removes the container, even if ctx is cancelled, so that no container is left behind
+func (b dockerBackend) removeContainer(ctx context.Context, id string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), _killTimeout)
+	defer cancel()
+	err := b.client.RemoveContainer(docker.RemoveContainerOptions{ID: id, Force: true, Context: ctx})
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("id", id).
+			Msg("Failed to remove container")
+	}
+}
+
+// setRawTerminal puts the terminal in raw mode, so that key presses like Ctrl-C reach the container
+// instead of being handled by the host terminal, and returns a function to restore it
+func setRawTerminal(input any) (func(), error) {
+	fd, isTerminal := term.GetFdInfo(input)
+	if !isTerminal {
+		return func() {}, nil
+	}
+
+	state, err := term.SetRawTerminal(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set terminal to raw mode: %w", err)
+	}
+	return func() { _ = term.RestoreTerminal(fd, state) }, nil
+}
+
+// inlineSeccompProfile replaces the seccomp profile file with its content, as the API,
+// unlike the docker CLI, expects the profile itself. The bundled profile is passed from memory,
+// not read back from the user cache directory, where it could have been modified.
+func inlineSeccompProfile(securityOpts []string) ([]string, error) {
+	bundledProfileFile, err := getSeccompProfileFile()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(securityOpts))
+	for _, securityOpt := range securityOpts {
+		profileFile, found := strings.CutPrefix(securityOpt, "seccomp=")
+		if !found || profileFile == "unconfined" {
+			result = append(result, securityOpt)
+			continue
+		}
+		if profileFile == bundledProfileFile {
+			result = append(result, "seccomp="+string(_seccompProfile))
+			continue
+		}
+
+		profile, err := os.ReadFile(profileFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read seccomp profile %s: %w", profileFile, err)
+		}
+		result = append(result, "seccomp="+string(profile))
+	}
+	return result, nil
+}
+
+// readEnvFiles reads env files the same way "docker run --env-file" does.
+// Each line is KEY=VALUE taken literally, or just KEY to pass the variable from the host.
+func readEnvFiles(envFiles []string) ([]string, error) {
+	env := make([]string, 0)
+	for _, envFile := range envFiles {
+		file, err := os.Open(envFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open env file %s: %w", envFile, err)
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimLeft(scanner.Text(), " \t")
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			if _, _, found := strings.Cut(line, "="); found {
+				env = append(env, line)
+			} else if value, ok := os.LookupEnv(line); ok {
+				env = append(env, line+"="+value)
+			}
+		}
+		err = scanner.Err()
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read env file %s: %w", envFile, err)
+		}
+	}
+	return env, nil
+}
@@ -0,0 +1,269 @@
+package cmdrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn" // asb works, but not fully
+	CheckFail CheckStatus = "fail" // asb cannot run the sandbox
+)
+
+// Warn when less than this is left for the images and the cache volumes
+const _minFreeDiskBytes = 5 << 30
+
+// Oldest backend versions that support all the run options asb uses: Docker 20.10 is the first one
+// with cgroup v2 support, which the resource limits need on current distros, and Podman 4.0 is the
+// first one with netavark, whose network subnets the egress proxy looks up
+var _minBackendVersions = map[string]string{
+	string(BackendDocker): "20.10",
+	string(BackendPodman): "4.0",
+}
+
+// Check is the result of one of the checks of Diagnose
+type Check struct {
+	Name        string      `json:"name"`
+	Status      CheckStatus `json:"status"`
+	Message     string      `json:"message"`
+	Remediation string      `json:"remediation,omitempty"` // How to fix the problem, if any
+}
+
+// Diagnose checks that the environment can run the sandbox, e.g. for a new machine
+func Diagnose(ctx context.Context, backendType BackendType, workingDir string) []Check {
+	checks := []Check{checkDockerBinary()}
+	checks = append(checks, checkBackend(ctx, backendType, workingDir)...)
+	return append(checks, checkCodingAgentConfigs()...)
+}
+
+func checkDockerBinary() Check {
+	path, err := exec.LookPath("docker")
+	if err != nil {
+		return Check{
+			Name:    "docker binary",
+			Status:  CheckWarn,
+			Message: "docker is not on PATH, asb does not need it but the commands printed by \"asb explain\" do",
+			Remediation: "Install the docker CLI, see https://docs.docker.com/engine/install/, " +
+				"or use Podman via --backend=podman",
+		}
+	}
+	return Check{Name: "docker binary", Status: CheckPass, Message: "Found " + path}
+}
+
+// checkBackend checks the backend and everything that needs it
+func checkBackend(ctx context.Context, backendType BackendType, workingDir string) []Check {
+	backend, err := NewBackend(ctx, backendType)
+	if err != nil {
+		return []Check{{
+			Name:        "backend",
+			Status:      CheckFail,
+			Message:     err.Error(),
+			Remediation: getBackendRemediation(err),
+		}}
+	}
+
+	checks := []Check{{Name: "backend", Status: CheckPass, Message: backend.Name() + " is running"}}
+	info, err := backend.Info(ctx)
+	if err != nil {
+		checks = append(checks, Check{Name: "backend version", Status: CheckFail, Message: err.Error()})
+	} else {
+		checks = append(checks, checkBackendVersion(backend, info), checkUserNamespace(backend, info),
+			checkFreeDiskSpace(backend, info))
+	}
+	return append(checks, checkToolImages(ctx, backend, workingDir)...)
+}
+
+func getBackendRemediation(err error) string {
+	if strings.Contains(err.Error(), "permission denied") {
+		return "Add your user to the docker group via \"sudo usermod -aG docker $USER\" and log in again"
+	}
+	return "Start Docker, e.g. via Docker Desktop or \"sudo systemctl start docker\", " +
+		"or install Podman, see https://podman.io/docs/installation, and use --backend=podman"
+}
+
+func checkBackendVersion(backend Backend, info BackendInfo) Check {
+	minVersion := _minBackendVersions[backend.Name()]
+	if info.Version == "" {
+		return Check{Name: "backend version", Status: CheckWarn, Message: "Unknown version of " + backend.Name()}
+	}
+
+	message := fmt.Sprintf("%s %s", backend.Name(), info.Version)
+	if !isVersionAtLeast(info.Version, minVersion) {
+		return Check{
+			Name:        "backend version",
+			Status:      CheckFail,
+			Message:     fmt.Sprintf("%s is older than %s", message, minVersion),
+			Remediation: fmt.Sprintf("Upgrade %s to %s or newer", backend.Name(), minVersion),
+		}
+	}
+	return Check{Name: "backend version", Status: CheckPass, Message: message}
+}
+
+// checkUserNamespace warns about the modes in which the files created by the sandbox
+// in the working directory are not owned by the host user
+func checkUserNamespace(backend Backend, info BackendInfo) Check {
+	switch {
+	case info.UsernsRemap:
+		return Check{
+			Name:    "user namespace",
+			Status:  CheckWarn,
+			Message: "userns-remap is enabled, files created by the sandbox are owned by a remapped user",
+			Remediation: "Disable \"userns-remap\" in the daemon config, " +
+				"or use rootless Podman via --backend=podman",
+		}
+	case info.Rootless && backend.Name() == string(BackendDocker):
+		return Check{
+			Name:    "user namespace",
+			Status:  CheckWarn,
+			Message: "Rootless Docker, files created by the sandbox are owned by a subordinate user",
+			Remediation: "Use --run-as-root, the sandbox's root is your user in rootless mode, " +
+				"or use rootless Podman via --backend=podman",
+		}
+	case info.Rootless:
+		return Check{Name: "user namespace", Status: CheckPass, Message: "Rootless, your user is mapped into the sandbox"}
+	default:
+		return Check{Name: "user namespace", Status: CheckPass, Message: "Rootful, running as your user inside the sandbox"}
+	}
+}
+
+func checkFreeDiskSpace(backend Backend, info BackendInfo) Check {
+	freeBytes, err := getFreeDiskBytes(info.VolumeDir)
+	if err != nil {
+		return Check{
+			Name:   "disk space",
+			Status: CheckWarn,
+			Message: fmt.Sprintf("Cannot check the free disk space of %s, e.g. as %s runs in a VM: %v",
+				info.VolumeDir, backend.Name(), err),
+			Remediation: fmt.Sprintf("Check the disk usage via \"%s system df\"", backend.Name()),
+		}
+	}
+
+	message := fmt.Sprintf("%.1f GiB free for the cache volumes in %s", float64(freeBytes)/(1<<30), info.VolumeDir)
+	if freeBytes < _minFreeDiskBytes {
+		return Check{
+			Name:    "disk space",
+			Status:  CheckWarn,
+			Message: message,
+			Remediation: fmt.Sprintf("Free up space, e.g. via \"asb cache prune\" or \"%s system prune\"",
+				backend.Name()),
+		}
+	}
+	return Check{Name: "disk space", Status: CheckPass, Message: message}
+}
+
+// checkToolImages checks that the images of the tools, for the versions requested by the project, are pulled
+func checkToolImages(ctx context.Context, backend Backend, workingDir string) []Check {
+	images, err := ToolImages(workingDir)
+	if err != nil {
+		return []Check{{Name: "images", Status: CheckFail, Message: err.Error()}}
+	}
+
+	checks := make([]Check, 0, len(images))
+	for _, image := range images {
+		name := "image " + image
+		exists, err := backend.ImageExists(ctx, image)
+		switch {
+		case err != nil:
+			checks = append(checks, Check{Name: name, Status: CheckFail, Message: err.Error()})
+		case exists:
+			checks = append(checks, Check{Name: name, Status: CheckPass, Message: "Pulled"})
+		default:
+			checks = append(checks, Check{
+				Name:        name,
+				Status:      CheckWarn,
+				Message:     "Not pulled yet, the first run of the tool pulls it",
+				Remediation: fmt.Sprintf("Pull it ahead of time via \"%s pull %s\"", backend.Name(), image),
+			})
+		}
+	}
+	return checks
+}
+
+// checkCodingAgentConfigs checks that the files and directories that setupDirMappingsForCodingAgents
+// creates, or mounts, are writable
+func checkCodingAgentConfigs() []Check {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return []Check{{Name: "coding agent configs", Status: CheckFail, Message: err.Error()}}
+	}
+
+	paths := []string{filepath.Join(homeDir, _claudeConfigFileName)}
+	for _, dirName := range _codingAgentConfigDirs {
+		paths = append(paths, filepath.Join(homeDir, dirName))
+	}
+
+	checks := make([]Check, 0, len(paths))
+	for _, path := range paths {
+		name := "coding agent config " + path
+		if err = checkWritable(path); err != nil {
+			checks = append(checks, Check{
+				Name:        name,
+				Status:      CheckFail,
+				Message:     err.Error(),
+				Remediation: fmt.Sprintf("Make it writable by your user, e.g. via \"sudo chown -R $USER %s\"", path),
+			})
+			continue
+		}
+		checks = append(checks, Check{Name: name, Status: CheckPass, Message: "Writable"})
+	}
+	return checks
+}
+
+// checkWritable checks that path can be written to, or created if it does not exist
+func checkWritable(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// It gets created in its parent directory
+		return checkDirWritable(filepath.Dir(path))
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return checkDirWritable(path)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", path, err)
+	}
+	return file.Close()
+}
+
+func checkDirWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".asb-doctor-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	_ = file.Close()
+	return os.Remove(file.Name())
+}
+
+// isVersionAtLeast compares the major and minor version, e.g. "24.0.7-1ubuntu" is at least "20.10"
+func isVersionAtLeast(version string, minVersion string) bool {
+	actual, minimum := parseMajorMinor(version), parseMajorMinor(minVersion)
+	if actual[0] != minimum[0] {
+		return actual[0] > minimum[0]
+	}
+	return actual[1] >= minimum[1]
+}
+
+func parseMajorMinor(version string) [2]int {
+	var result [2]int
+	fields := strings.FieldsFunc(version, func(r rune) bool { return !unicode.IsDigit(r) })
+	for i := 0; i < len(result) && i < len(fields); i++ {
+		result[i], _ = strconv.Atoi(fields[i])
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package cmdrunner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/ashishb/asb/src/asb/internal/egressproxy"
+)
+
+// Name of the internal network used by NetworkEgressProxy.
+// Containers on it cannot reach the internet, only the host running the proxy.
+const _egressNetworkName = "asb-egress"
+
+func AddAllowedDomains(domains []string) Option {
+	return func(c *Config) {
+		c.allowedDomains = append(c.allowedDomains, domains...)
+	}
+}
+
+func (c Config) getAllowedDomains() []string {
+	return slices.Concat(c.tool.AllowedDomains, c.allowedDomains)
+}
+
+// startEgressProxy starts the proxy on the host side of the internal network and returns
+// the address the container should use to reach it
+func startEgressProxy(ctx context.Context, backend Backend, config Config) (*egressproxy.Proxy, string, error) {
+	log.Debug().
+		Str("network", _egressNetworkName).
+		Msg("Setting up internal network for the egress proxy")
+	gateway, err := backend.EnsureNetwork(ctx, _egressNetworkName, true)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// With Docker Desktop, or Podman on macOS and Windows, the gateway is inside the engine's VM,
+	// so the proxy on this host cannot listen on it
+	local, err := isLocalAddress(gateway)
+	if err != nil {
+		return nil, "", err
+	}
+	if !local {
+		return nil, "", fmt.Errorf("the %s network gateway %s is not an address of this host, e.g. with Docker Desktop "+
+			"it is inside the VM, so the %q network only works with an engine running on this host, e.g. on Linux",
+			_egressNetworkName, gateway, NetworkEgressProxy)
+	}
+
+	proxy := egressproxy.New(config.getAllowedDomains())
+	address, err := proxy.Start(ctx, net.JoinHostPort(gateway, "0"))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to start egress proxy on the %s network gateway, "+
+			"this network mode needs the %s network to be reachable from this host: %w",
+			_egressNetworkName, backend.Name(), err)
+	}
+
+	log.Info().
+		Strs("allowedDomains", config.getAllowedDomains()).
+		Msg("Network access is restricted to the allowed domains")
+	return proxy, address, nil
+}
+
+// getEgressProxyEnv returns the environment variables that point the tools at the proxy
+func getEgressProxyEnv(proxyAddress string) []string {
+	proxyURL := "http://" + proxyAddress
+	env := make([]string, 0)
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		env = append(env, fmt.Sprintf("%s=%s", name, proxyURL))
+	}
+	return append(env, "NO_PROXY=localhost,127.0.0.1", "no_proxy=localhost,127.0.0.1")
+}
+
+// isLocalAddress returns true if the IP address is assigned to a network interface of this host
+func isLocalAddress(ip string) (bool, error) {
+	addresses, err := net.InterfaceAddrs()
+	if err != nil {
+		return false, fmt.Errorf("failed to list the network interface addresses: %w", err)
+	}
+
+	parsedIP := net.ParseIP(ip)
+	return slices.ContainsFunc(addresses, func(address net.Addr) bool {
+		ipNet, ok := address.(*net.IPNet)
+		return ok && ipNet.IP.Equal(parsedIP)
+	}), nil
+}
@@ -0,0 +1,33 @@
+package cmdrunner
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrDockerUnavailable means that no container backend, Docker or Podman, is installed and running
+	ErrDockerUnavailable = errors.New("container backend is not available")
+	// ErrUnsupportedTool means that the command type is not in the tool registry
+	ErrUnsupportedTool = errors.New("unsupported tool")
+)
+
+// ExitError is returned when the command ran but exited with a non-zero exit code
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command exited with code %d", e.Code)
+}
+
+// SensitivePathError is returned when a mount would expose a sensitive path
+type SensitivePathError struct {
+	Source    string // Host path that was requested to be mounted
+	Sensitive string // Sensitive path it exposes
+	Purpose   string // Why the mount was requested, e.g. "working directory"
+}
+
+func (e *SensitivePathError) Error() string {
+	return fmt.Sprintf("refusing to mount %s (%s) as it exposes the sensitive path %s", e.Source, e.Purpose, e.Sensitive)
+}
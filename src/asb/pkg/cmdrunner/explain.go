@@ -0,0 +1,171 @@
+package cmdrunner
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Placeholder for the address of the egress proxy, which is only known once it is running
+const _egressProxyPlaceholder = "<egress-proxy-address>"
+
+// Characters that need no quoting in a POSIX shell
+var _shellSafeRegex = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Explanation describes the sandbox that RunCmd would create for a config, without creating it
+type Explanation struct {
+	CmdType CmdType     `json:"cmdType"`
+	Backend BackendType `json:"backend"`
+	Image   string      `json:"image"`
+	// How the toolchain version was selected, nil if the tool's default image is used
+	ToolVersion *ToolVersion `json:"toolVersion,omitempty"`
+	Entrypoint  []string     `json:"entrypoint,omitempty"` // Empty means the image's entrypoint
+	Command     []string     `json:"command"`
+	WorkingDir  string       `json:"workingDir"`
+	User        string       `json:"user"` // "uid:gid", empty means root
+
+	Network        string   `json:"network"`
+	AllowedDomains []string `json:"allowedDomains,omitempty"` // Only with the "proxy" network
+	PublishedPorts []string `json:"publishedPorts,omitempty"`
+
+	Mounts          []ExplainedMount `json:"mounts"`
+	ReferencedFiles []string         `json:"referencedFiles"`
+	EnvFiles        []string         `json:"envFiles"`
+	Env             []string         `json:"env"`
+
+	Resources ExplainedResources `json:"resources"`
+	Security  ExplainedSecurity  `json:"security"`
+
+	// Equivalent command that can be copy-pasted into a shell
+	CLICommand string `json:"cliCommand"`
+}
+
+type ExplainedMount struct {
+	Purpose  string    `json:"purpose"`
+	Type     MountType `json:"type"`
+	Source   string    `json:"source,omitempty"`
+	Target   string    `json:"target"`
+	ReadOnly bool      `json:"readOnly"`
+}
+
+type ExplainedResources struct {
+	MemoryBytes int64   `json:"memoryBytes,omitempty"`
+	CPUs        float64 `json:"cpus,omitempty"`
+	PidsLimit   int64   `json:"pidsLimit,omitempty"`
+	Timeout     string  `json:"timeout,omitempty"`
+}
+
+type ExplainedSecurity struct {
+	Hardened       bool     `json:"hardened"`
+	ReadOnlyRootFS bool     `json:"readOnlyRootFS"`
+	CapDrop        []string `json:"capDrop,omitempty"`
+	CapAdd         []string `json:"capAdd,omitempty"`
+	SecurityOpts   []string `json:"securityOpts,omitempty"`
+}
+
+// Explain returns what RunCmd would do for this config.
+// It runs the same checks as RunCmd, but does not talk to the container backend.
+func Explain(config Config) (Explanation, error) {
+	config, err := config.selectToolchainImage()
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	if err := config.validate(); err != nil {
+		return Explanation{}, err
+	}
+
+	if config, err = config.checkSensitiveMounts(); err != nil {
+		return Explanation{}, err
+	}
+
+	if err := config.checkPolicy(); err != nil {
+		return Explanation{}, err
+	}
+
+	if config.networkType == NetworkEgressProxy {
+		config.egressProxyAddress = _egressProxyPlaceholder
+	}
+
+	spec, err := getContainerSpec(config)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	explanation := Explanation{
+		CmdType:         config.cmdType,
+		Backend:         config.backendType,
+		Image:           spec.Image,
+		Entrypoint:      spec.Entrypoint,
+		Command:         spec.Cmd,
+		WorkingDir:      spec.WorkingDir,
+		User:            spec.User,
+		Network:         spec.Network,
+		Mounts:          explainMounts(spec.Mounts),
+		ReferencedFiles: config.getReferencedFiles(),
+		EnvFiles:        spec.EnvFiles,
+		Env:             spec.Env,
+		Resources: ExplainedResources{
+			MemoryBytes: spec.Resources.MemoryBytes,
+			CPUs:        spec.Resources.CPUs,
+			PidsLimit:   spec.Resources.PidsLimit,
+		},
+		Security: ExplainedSecurity{
+			Hardened:       !config.insecure,
+			ReadOnlyRootFS: spec.ReadOnlyRootFS,
+			CapDrop:        spec.CapDrop,
+			CapAdd:         spec.CapAdd,
+			SecurityOpts:   spec.SecurityOpts,
+		},
+		CLICommand: shellQuote(getCLICommand(config.backendType, spec)),
+	}
+
+	if config.toolVersion.Selected != "" {
+		explanation.ToolVersion = &config.toolVersion
+	}
+	if config.networkType == NetworkEgressProxy {
+		explanation.AllowedDomains = config.getAllowedDomains()
+	}
+	if config.resourceLimits.Timeout > 0 {
+		explanation.Resources.Timeout = config.resourceLimits.Timeout.String()
+	}
+	for _, mapping := range spec.Publish {
+		explanation.PublishedPorts = append(explanation.PublishedPorts, mapping.String())
+	}
+	return explanation, nil
+}
+
+func explainMounts(mounts []Mount) []ExplainedMount {
+	result := make([]ExplainedMount, 0, len(mounts))
+	for _, mount := range mounts {
+		result = append(result, ExplainedMount{
+			Purpose:  mount.purpose,
+			Type:     mount.Type,
+			Source:   mount.Source,
+			Target:   mount.Target,
+			ReadOnly: mount.ReadOnly,
+		})
+	}
+	return result
+}
+
+// getCLICommand returns the "docker run" or "podman run" command equivalent to the spec
+func getCLICommand(backendType BackendType, spec ContainerSpec) []string {
+	if backendType == BackendPodman {
+		spec, extraArgs := getPodmanRunSpec(spec)
+		return append([]string{_podmanBinary}, spec.cliRunArgs(extraArgs...)...)
+	}
+	return append([]string{"docker"}, spec.cliRunArgs()...)
+}
+
+// shellQuote joins the args into a command line that a POSIX shell splits back into the same args
+func shellQuote(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if _shellSafeRegex.MatchString(arg) {
+			quoted = append(quoted, arg)
+			continue
+		}
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
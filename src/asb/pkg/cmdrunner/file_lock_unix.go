@@ -0,0 +1,26 @@
+//go:build !windows
+
+package cmdrunner
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on the file, created if needed, and returns a function that releases it.
+// The lock is released by the OS if the process dies while holding it.
+func lockFile(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %s: %w", path, err)
+	}
+
+	//nolint:gosec // File descriptors fit in an int
+	if err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	// Closing the file releases the lock
+	return func() { _ = file.Close() }, nil
+}
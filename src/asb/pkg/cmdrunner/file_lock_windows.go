@@ -0,0 +1,9 @@
+//go:build windows
+
+package cmdrunner
+
+// lockFile is a no-op, as the files it guards are only updated on a best-effort basis,
+// and a lost update on Windows is not worth a dependency on the Windows API
+func lockFile(string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,31 @@
+package cmdrunner
+
+import (
+	"github.com/ashishb/asb/src/asb/pkg/registry"
+)
+
+// CmdTypeImage runs a command in any image chosen by the user, see SetImage.
+// It gets the same sandbox as the tools in the registry, but no cache volumes, as the image is not trusted.
+const CmdTypeImage CmdType = "run"
+
+// SetImage sets the image to run the command in, it is required with CmdTypeImage
+func SetImage(image string) Option {
+	return func(c *Config) {
+		c.dockerBaseImage = image
+	}
+}
+
+// SetEntrypoint overrides the image's entrypoint, an empty entrypoint keeps the image's one
+func SetEntrypoint(entrypoint []string) Option {
+	return func(c *Config) {
+		c.entrypoint = entrypoint
+	}
+}
+
+// getTool returns the registry entry of the command type
+func getTool(cmdType CmdType) (registry.Tool, bool) {
+	if cmdType == CmdTypeImage {
+		return registry.Tool{Name: string(CmdTypeImage), Command: string(CmdTypeImage)}, true
+	}
+	return _toolRegistry.Tool(string(cmdType))
+}
@@ -0,0 +1,110 @@
+package cmdrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SetImageDigests pins images to the given digests, keyed by the image reference, e.g. "node:25-bookworm".
+// An image without a digest is run by its tag.
+func SetImageDigests(digests map[string]string) Option {
+	return func(c *Config) {
+		c.imageDigests = digests
+	}
+}
+
+// getImage returns the image to run, pinned to its digest if one is set
+func (c Config) getImage() string {
+	if digest := c.imageDigests[c.dockerBaseImage]; digest != "" {
+		return pinImage(c.dockerBaseImage, digest)
+	}
+	return c.dockerBaseImage
+}
+
+// ResolveImageDigests looks up the current digest of each image in its registry
+func ResolveImageDigests(ctx context.Context, backendType BackendType, images []string) (map[string]string, error) {
+	backend, err := NewBackend(ctx, backendType)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make(map[string]string, len(images))
+	var errs []error
+	for _, image := range images {
+		digest, err := backend.ResolveImageDigest(ctx, image)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		log.Debug().
+			Str("image", image).
+			Str("digest", digest).
+			Msg("Resolved image digest")
+		digests[image] = digest
+	}
+	return digests, errors.Join(errs...)
+}
+
+// ToolImages returns the images that the tools with a command use in the given working directory,
+// i.e. for the toolchain versions requested by the project files
+func ToolImages(workingDir string) ([]string, error) {
+	images := make([]string, 0, len(_toolRegistry.Tools))
+	for _, tool := range _toolRegistry.Tools {
+		if tool.Hidden {
+			continue
+		}
+
+		config, err := NewConfig(CmdType(tool.Name), SetWorkingDir(workingDir))
+		if err != nil {
+			return nil, err
+		}
+		config, err = config.selectToolchainImage()
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(images, config.dockerBaseImage) {
+			images = append(images, config.dockerBaseImage)
+		}
+	}
+	slices.Sort(images)
+	return images, nil
+}
+
+// pinImage replaces the tag of the image with the digest, e.g. "node@sha256:..." for "node:25-bookworm"
+func pinImage(image string, digest string) string {
+	name, _, _ := strings.Cut(image, "@")
+	if index := strings.LastIndex(name, ":"); index > strings.LastIndex(name, "/") {
+		name = name[:index]
+	}
+	return name + "@" + digest
+}
+
+// verifyImageDigest checks that the local image is the one pinned by the digest
+func verifyImageDigest(ctx context.Context, backend Backend, image string, digest string) error {
+	digests, err := backend.ImageDigests(ctx, image)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(digests, digest) {
+		return fmt.Errorf("image %s does not match the pinned digest %s, its digests are %q", image, digest, digests)
+	}
+	return nil
+}
+
+// getRepoDigests returns the digests of "repo@digest" references
+func getRepoDigests(repoDigests []string) []string {
+	digests := make([]string, 0, len(repoDigests))
+	for _, repoDigest := range repoDigests {
+		if _, digest, found := strings.Cut(repoDigest, "@"); found && !slices.Contains(digests, digest) {
+			digests = append(digests, digest)
+		}
+	}
+	return digests
+}
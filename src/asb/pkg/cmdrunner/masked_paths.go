@@ -0,0 +1,118 @@
+package cmdrunner
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ashishb/asb/src/asb/internal/ignorefile"
+	"github.com/rs/zerolog/log"
+)
+
+// Lists the files and directories inside the working directory to hide from the sandbox, in the gitignore syntax
+const _asbIgnoreFileName = ".asbignore"
+
+// Mount options of the empty tmpfs that shadows a masked directory
+const _maskedDirOptions = "ro,nosuid,nodev,noexec"
+
+// getMaskedPathMounts returns the mounts that shadow the paths of the working directory matched by its
+// .asbignore file, e.g. ".env" or "*.pem": an empty read-only tmpfs for a directory and an empty
+// read-only file for a file, so that a tool sees the project but not its secrets
+func (c Config) getMaskedPathMounts() ([]Mount, error) {
+	if !c.mountWorkingDirRW && !c.mountWorkingDirRO {
+		return nil, nil
+	}
+
+	matcher, err := ignorefile.Load(filepath.Join(c.workingDir, _asbIgnoreFileName))
+	if err != nil || matcher == nil {
+		return nil, err
+	}
+
+	mounts := make([]Mount, 0)
+	emptyFile := ""
+	err = filepath.WalkDir(c.workingDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// E.g. a directory that cannot be read, which the sandbox cannot read either
+			log.Debug().
+				Err(err).
+				Str("path", path).
+				Msg("Skipping path while looking for masked paths")
+			return nil
+		}
+
+		relPath, err := filepath.Rel(c.workingDir, path)
+		if err != nil || relPath == "." {
+			return nil
+		}
+		if !matcher.Match(filepath.ToSlash(relPath), entry.IsDir()) {
+			// E.g. node_modules, unless a pattern without a "/" can match a name at any depth
+			if entry.IsDir() && !matcher.MayMatchInside(filepath.ToSlash(relPath)) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case entry.Type()&fs.ModeSymlink != 0:
+			// Mounting over a symlink mounts over its target inside the container, which can be anywhere
+			log.Warn().
+				Str("path", path).
+				Msg("Not masking the symlink, mask the path it points to instead")
+			return nil
+		case entry.IsDir():
+			mounts = append(mounts, Mount{
+				Type:     MountTypeTmpfs,
+				Target:   path,
+				ReadOnly: true,
+				Options:  _maskedDirOptions,
+				purpose:  _mountPurposeMasked,
+			})
+			return fs.SkipDir
+		default:
+			if emptyFile == "" {
+				if emptyFile, err = writeEmptyFile(); err != nil {
+					return err
+				}
+			}
+			mounts = append(mounts, Mount{
+				Type:     MountTypeBind,
+				Source:   emptyFile,
+				Target:   path,
+				ReadOnly: true,
+				purpose:  _mountPurposeMasked,
+			})
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the paths masked by %s: %w", _asbIgnoreFileName, err)
+	}
+
+	log.Debug().
+		Int("count", len(mounts)).
+		Str("workingDir", c.workingDir).
+		Msg("Masking paths matched by " + _asbIgnoreFileName)
+	return mounts, nil
+}
+
+// writeEmptyFile returns the empty file that is mounted over the masked files, creating it if needed
+func writeEmptyFile() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+
+	emptyFile := filepath.Join(cacheDir, "asb", "empty")
+	if info, err := os.Stat(emptyFile); err == nil && info.Size() == 0 {
+		return emptyFile, nil
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to check empty file %s: %w", emptyFile, err)
+	}
+
+	if err = writeFileAtomic(emptyFile, nil, 0o444); err != nil {
+		return "", fmt.Errorf("failed to write empty file: %w", err)
+	}
+	return emptyFile, nil
+}
@@ -0,0 +1,223 @@
+package cmdrunner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const _podmanBinary = "podman"
+
+// podmanBackend runs the sandbox with rootless Podman, which needs no daemon.
+// Podman's CLI is compatible with the docker CLI, so the same run arguments work for both.
+type podmanBackend struct{}
+
+var _ Backend = podmanBackend{}
+
+func newPodmanBackend() (podmanBackend, error) {
+	if _, err := exec.LookPath(_podmanBinary); err != nil {
+		return podmanBackend{}, fmt.Errorf("podman is not installed: %w", err)
+	}
+	return podmanBackend{}, nil
+}
+
+func (b podmanBackend) Name() string {
+	return string(BackendPodman)
+}
+
+func (b podmanBackend) Ping(ctx context.Context) error {
+	if _, err := b.output(ctx, "info", "--format={{.Host.Arch}}"); err != nil {
+		return fmt.Errorf("podman is not working: %w", err)
+	}
+	return nil
+}
+
+func (b podmanBackend) Info(ctx context.Context) (BackendInfo, error) {
+	output, err := b.output(ctx, "info", "--format=json")
+	if err != nil {
+		return BackendInfo{}, fmt.Errorf("failed to get podman info: %w", err)
+	}
+
+	var info struct {
+		Host struct {
+			CPUs     int `json:"cpus"`
+			Security struct {
+				Rootless bool `json:"rootless"`
+			} `json:"security"`
+		} `json:"host"`
+		Store struct {
+			VolumePath string `json:"volumePath"`
+		} `json:"store"`
+		Version struct {
+			Version string `json:"Version"`
+		} `json:"version"`
+	}
+	if err = json.Unmarshal([]byte(output), &info); err != nil {
+		return BackendInfo{}, fmt.Errorf("failed to parse podman info: %w", err)
+	}
+	return BackendInfo{
+		Version:   info.Version.Version,
+		Rootless:  info.Host.Security.Rootless,
+		VolumeDir: info.Store.VolumePath,
+		CPUs:      info.Host.CPUs,
+	}, nil
+}
+
+func (b podmanBackend) ImageExists(ctx context.Context, image string) (bool, error) {
+	return b.exists(ctx, "image", qualifyImageName(image))
+}
+
+func (b podmanBackend) PullImage(ctx context.Context, image string) error {
+	//nolint:gosec  // Image name comes from asb's own config
+	cmd := exec.CommandContext(ctx, _podmanBinary, "pull", qualifyImageName(image))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// ResolveImageDigest pulls the image, as Podman cannot look up a digest without pulling
+func (b podmanBackend) ResolveImageDigest(ctx context.Context, image string) (string, error) {
+	if err := b.PullImage(ctx, image); err != nil {
+		return "", fmt.Errorf("failed to pull image %s: %w", image, err)
+	}
+
+	digest, err := b.output(ctx, "image", "inspect", "--format={{.Digest}}", qualifyImageName(image))
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	return strings.TrimSpace(digest), nil
+}
+
+func (b podmanBackend) ImageDigests(ctx context.Context, image string) ([]string, error) {
+	output, err := b.output(ctx, "image", "inspect", "--format={{json .RepoDigests}}", qualifyImageName(image))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+
+	var repoDigests []string
+	if err = json.Unmarshal([]byte(output), &repoDigests); err != nil {
+		return nil, fmt.Errorf("failed to parse digests of image %s: %w", image, err)
+	}
+	return getRepoDigests(repoDigests), nil
+}
+
+func (b podmanBackend) ListVolumes(ctx context.Context) ([]VolumeInfo, error) {
+	output, err := b.output(ctx, "volume", "ls", "--format=json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	var volumes []VolumeInfo
+	if err = json.Unmarshal([]byte(output), &volumes); err != nil {
+		return nil, fmt.Errorf("failed to parse volume list: %w", err)
+	}
+	return volumes, nil
+}
+
+func (b podmanBackend) VolumeExists(ctx context.Context, name string) (bool, error) {
+	return b.exists(ctx, "volume", name)
+}
+
+func (b podmanBackend) CreateVolume(ctx context.Context, name string, labels map[string]string) error {
+	args := []string{"volume", "create"}
+	for key, value := range labels {
+		args = append(args, fmt.Sprintf("--label=%s=%s", key, value))
+	}
+	if _, err := b.output(ctx, append(args, name)...); err != nil {
+		return fmt.Errorf("failed to create volume %s: %w", name, err)
+	}
+	return nil
+}
+
+func (b podmanBackend) RemoveVolume(ctx context.Context, name string) error {
+	if _, err := b.output(ctx, "volume", "rm", name); err != nil {
+		return fmt.Errorf("failed to remove volume %s: %w", name, err)
+	}
+	return nil
+}
+
+func (b podmanBackend) EnsureNetwork(ctx context.Context, name string, internal bool) (string, error) {
+	exists, err := b.exists(ctx, "network", name)
+	if err != nil {
+		return "", err
+	}
+
+	if !exists {
+		args := []string{"network", "create"}
+		if internal {
+			args = append(args, "--internal")
+		}
+		if _, err = b.output(ctx, append(args, name)...); err != nil {
+			return "", fmt.Errorf("failed to create network %s: %w", name, err)
+		}
+	}
+
+	gateways, err := b.output(ctx, "network", "inspect", "--format={{range .Subnets}}{{.Gateway}} {{end}}", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect network %s: %w", name, err)
+	}
+
+	for gateway := range strings.FieldsSeq(gateways) {
+		if ip := net.ParseIP(gateway); ip != nil && ip.To4() != nil {
+			return gateway, nil
+		}
+	}
+	return "", fmt.Errorf("network %s has no IPv4 gateway", name)
+}
+
+// Run runs the container with the podman CLI, which removes the container on exit, so whether it was
+// killed for exceeding the memory limit is not known
+func (b podmanBackend) Run(ctx context.Context, spec ContainerSpec) (ExitState, error) {
+	spec, extraArgs := getPodmanRunSpec(spec)
+	exitCode, err := runWithCLI(ctx, _podmanBinary, spec, extraArgs...)
+	return ExitState{ExitCode: exitCode}, err
+}
+
+// getPodmanRunSpec adapts the spec to Podman and returns the Podman-only "run" arguments
+func getPodmanRunSpec(spec ContainerSpec) (ContainerSpec, []string) {
+	spec.Image = qualifyImageName(spec.Image)
+	extraArgs := make([]string, 0)
+	if spec.User != "" && os.Getuid() > 0 {
+		// Map the host user to the same uid inside the container, so that the files
+		// it creates in the bind mounts are owned by the host user
+		extraArgs = append(extraArgs, "--userns=keep-id")
+	}
+	return spec, extraArgs
+}
+
+// exists runs "podman <objectType> exists", which signals the result via the exit code
+func (b podmanBackend) exists(ctx context.Context, objectType string, name string) (bool, error) {
+	_, err := b.output(ctx, objectType, "exists", name)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect %s %s: %w", objectType, name, err)
+	}
+	return true, nil
+}
+
+func (b podmanBackend) output(ctx context.Context, args ...string) (string, error) {
+	output, err := exec.CommandContext(ctx, _podmanBinary, args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return string(output), err
+}
+
+// qualifyImageName prefixes Docker Hub to image names without a registry, as Podman
+// either prompts for or guesses the registry of such short names
+func qualifyImageName(image string) string {
+	registry, _, found := strings.Cut(image, "/")
+	if found && (strings.ContainsAny(registry, ".:") || registry == "localhost") {
+		return image
+	}
+	return "docker.io/" + image
+}
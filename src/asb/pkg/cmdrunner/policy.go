@@ -0,0 +1,128 @@
+package cmdrunner
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Policy is a set of hard limits that the sandbox config must not loosen
+type Policy struct {
+	Source       string   // Where the policy came from, used in error messages
+	NoNetwork    bool     // Network must be disabled
+	ReadOnly     bool     // Disk access, if any, must be read-only
+	NoLoadEnv    bool     // .env file must not be loaded
+	NoInsecure   bool     // Hardened security profile cannot be disabled
+	DeniedMounts []string // Absolute paths that must never be mounted, fully or partially
+}
+
+// PolicyViolationError is returned when the requested config violates the policy
+type PolicyViolationError struct {
+	Source  string
+	CmdType CmdType
+	Reason  string
+}
+
+func (e *PolicyViolationError) Error() string {
+	return fmt.Sprintf("policy %s does not allow %s for %s", e.Source, e.Reason, e.CmdType)
+}
+
+func SetPolicy(policy Policy) Option {
+	return func(c *Config) {
+		c.policy = policy
+	}
+}
+
+func (c Config) checkPolicy() error {
+	violation := func(reason string) error {
+		return &PolicyViolationError{Source: c.policy.Source, CmdType: c.cmdType, Reason: reason}
+	}
+
+	if c.policy.NoNetwork && c.networkType != NetworkNone {
+		return violation(fmt.Sprintf("network access (requested network %q)", c.networkType))
+	}
+
+	if c.policy.ReadOnly && c.hasReadWriteMount() {
+		return violation("read-write disk access")
+	}
+
+	if c.policy.NoLoadEnv && c.loadDotEnv {
+		return violation("loading the .env file")
+	}
+
+	if c.policy.NoInsecure && c.insecure {
+		return violation("disabling the hardened security profile")
+	}
+
+	sources, err := c.getMountSources()
+	if err != nil {
+		return err
+	}
+
+	for _, source := range sources {
+		for _, denied := range c.policy.DeniedMounts {
+			if pathsOverlap(source, denied) {
+				return violation(fmt.Sprintf("mounting %s as it exposes %s", source, denied))
+			}
+		}
+	}
+	return nil
+}
+
+func (c Config) hasReadWriteMount() bool {
+	if c.mountWorkingDirRW || c.mountReferencedDirRW || len(c.getWritablePaths()) > 0 {
+		return true
+	}
+
+	for _, mount := range c.extraMounts {
+		if !mount.readOnly {
+			return true
+		}
+	}
+
+	// The config files of the coding agents, the error is reported when the mounts are created
+	agentMounts, err := getCodingAgentMounts(c)
+	return err == nil && slices.ContainsFunc(agentMounts, func(mount Mount) bool {
+		return !mount.ReadOnly
+	})
+}
+
+// getMountSources returns the host paths of all the bind mounts this config would create
+func (c Config) getMountSources() ([]string, error) {
+	var sources []string
+	if c.mountWorkingDirRW || c.mountWorkingDirRO {
+		sources = append(sources, c.workingDir)
+	}
+
+	if c.mountReferencedDirRW || c.mountReferencedDirRO {
+		sources = append(sources, c.getReferencedFiles()...)
+	}
+
+	for _, mount := range c.extraMounts {
+		sources = append(sources, mount.source)
+	}
+
+	agentMounts, err := getCodingAgentMounts(c)
+	if err != nil {
+		return nil, err
+	}
+	for _, mount := range agentMounts {
+		sources = append(sources, mount.Source)
+	}
+	return sources, nil
+}
+
+// pathsOverlap returns true if path1 and path2 are the same or one of them contains the other
+func pathsOverlap(path1 string, path2 string) bool {
+	return isSubPath(path1, path2) || isSubPath(path2, path1)
+}
+
+// isSubPath returns true if child is the same as parent or is inside it
+func isSubPath(parent string, child string) bool {
+	rel, err := filepath.Rel(filepath.Clean(parent), filepath.Clean(child))
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,38 @@
+package cmdrunner
+
+import (
+	"testing"
+
+	"github.com/ashishb/asb/src/asb/pkg/registry"
+)
+
+func TestHasReadWriteMountOfAgentConfigs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	agent := registry.Tool{Name: "claude", Command: "claude", MountAgentConfigs: true}
+	tests := []struct {
+		name   string
+		config Config
+		want   bool
+	}{
+		{name: "read-only", config: Config{tool: agent, mountReferencedDirRO: true}, want: false},
+		{name: "no disk access", config: Config{tool: agent}, want: false},
+		{name: "read-only without agent configs", config: Config{mountReferencedDirRO: true}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.hasReadWriteMount(); got != tt.want {
+				t.Errorf("hasReadWriteMount() = %t, want %t", got, tt.want)
+			}
+			mounts, err := getCodingAgentMounts(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, mount := range mounts {
+				if !mount.ReadOnly {
+					t.Errorf("Agent config %s is mounted read-write", mount.Source)
+				}
+			}
+		})
+	}
+}
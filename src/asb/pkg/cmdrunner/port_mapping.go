@@ -0,0 +1,97 @@
+package cmdrunner
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// By default, ports are only published on the loopback interface so that the dev server
+// is reachable from the browser but not from the rest of the network
+const _defaultPublishIP = "127.0.0.1"
+
+// PortMapping publishes a container port on the host
+type PortMapping struct {
+	HostIP        string
+	HostPort      int
+	ContainerPort int
+	Protocol      string // "tcp" or "udp"
+}
+
+func (p PortMapping) String() string {
+	return fmt.Sprintf("%s:%d:%d/%s", p.dockerHostIP(), p.HostPort, p.ContainerPort, p.Protocol)
+}
+
+func (p PortMapping) dockerHostIP() string {
+	if strings.Contains(p.HostIP, ":") {
+		return "[" + p.HostIP + "]"
+	}
+	return p.HostIP
+}
+
+// ParsePortMapping parses a port mapping of the form "[hostIP:][hostPort:]containerPort[/protocol]".
+// If the host port is omitted, it is the same as the container port.
+// IPv6 host IPs must be enclosed in brackets, e.g. "[::1]:3000:3000".
+func ParsePortMapping(value string) (PortMapping, error) {
+	mapping := PortMapping{HostIP: _defaultPublishIP, Protocol: "tcp"}
+	ports, protocol, hasProtocol := strings.Cut(value, "/")
+	if hasProtocol {
+		if protocol != "tcp" && protocol != "udp" {
+			return PortMapping{}, fmt.Errorf("invalid protocol %q in port mapping %q", protocol, value)
+		}
+		mapping.Protocol = protocol
+	}
+
+	if rest, ok := strings.CutPrefix(ports, "["); ok {
+		hostIP, afterIP, found := strings.Cut(rest, "]:")
+		if !found || net.ParseIP(hostIP) == nil {
+			return PortMapping{}, fmt.Errorf("invalid host IP in port mapping %q", value)
+		}
+		mapping.HostIP = hostIP
+		ports = afterIP
+		if !strings.Contains(ports, ":") {
+			return PortMapping{}, fmt.Errorf("host port is required with a host IP in port mapping %q", value)
+		}
+	}
+
+	parts := strings.Split(ports, ":")
+	if len(parts) == 3 {
+		if net.ParseIP(parts[0]) == nil {
+			return PortMapping{}, fmt.Errorf("invalid host IP %q in port mapping %q", parts[0], value)
+		}
+		mapping.HostIP = parts[0]
+		parts = parts[1:]
+	}
+
+	if len(parts) > 2 {
+		return PortMapping{}, fmt.Errorf("invalid port mapping %q", value)
+	}
+
+	var err error
+	if mapping.ContainerPort, err = parsePort(parts[len(parts)-1]); err != nil {
+		return PortMapping{}, fmt.Errorf("invalid container port in port mapping %q: %w", value, err)
+	}
+
+	mapping.HostPort = mapping.ContainerPort
+	if len(parts) == 2 {
+		if mapping.HostPort, err = parsePort(parts[0]); err != nil {
+			return PortMapping{}, fmt.Errorf("invalid host port in port mapping %q: %w", value, err)
+		}
+	}
+	return mapping, nil
+}
+
+func AddPublishedPorts(mappings []PortMapping) Option {
+	return func(c *Config) {
+		c.publishedPorts = append(c.publishedPorts, mappings...)
+	}
+}
+
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("%q is not a valid port number", value)
+	}
+	return port, nil
+}
@@ -0,0 +1,85 @@
+package cmdrunner
+
+import (
+	"testing"
+)
+
+func TestParsePortMapping(t *testing.T) {
+	tests := []struct {
+		value      string
+		want       PortMapping
+		wantString string
+		wantErr    bool
+	}{
+		// Host port and IP default to the container port and the loopback interface
+		{
+			value:      "3000",
+			want:       PortMapping{HostIP: "127.0.0.1", HostPort: 3000, ContainerPort: 3000, Protocol: "tcp"},
+			wantString: "127.0.0.1:3000:3000/tcp",
+		},
+		{
+			value:      "8080:3000",
+			want:       PortMapping{HostIP: "127.0.0.1", HostPort: 8080, ContainerPort: 3000, Protocol: "tcp"},
+			wantString: "127.0.0.1:8080:3000/tcp",
+		},
+		{
+			value:      "0.0.0.0:8080:3000",
+			want:       PortMapping{HostIP: "0.0.0.0", HostPort: 8080, ContainerPort: 3000, Protocol: "tcp"},
+			wantString: "0.0.0.0:8080:3000/tcp",
+		},
+		{
+			value:      "[::1]:8080:3000",
+			want:       PortMapping{HostIP: "::1", HostPort: 8080, ContainerPort: 3000, Protocol: "tcp"},
+			wantString: "[::1]:8080:3000/tcp",
+		},
+
+		// Protocols
+		{
+			value:      "5353/udp",
+			want:       PortMapping{HostIP: "127.0.0.1", HostPort: 5353, ContainerPort: 5353, Protocol: "udp"},
+			wantString: "127.0.0.1:5353:5353/udp",
+		},
+		{
+			value:      "127.0.0.1:8080:3000/tcp",
+			want:       PortMapping{HostIP: "127.0.0.1", HostPort: 8080, ContainerPort: 3000, Protocol: "tcp"},
+			wantString: "127.0.0.1:8080:3000/tcp",
+		},
+		{value: "3000/sctp", wantErr: true},
+		{value: "3000/", wantErr: true},
+		{value: "3000/TCP", wantErr: true},
+
+		// Invalid ports
+		{value: "", wantErr: true},
+		{value: "http", wantErr: true},
+		{value: "0", wantErr: true},
+		{value: "65536", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "8080:", wantErr: true},
+		{value: ":3000", wantErr: true},
+		{value: "70000:3000", wantErr: true},
+		{value: "1:2:3:4", wantErr: true},
+
+		// Invalid host IPs
+		{value: "localhost:8080:3000", wantErr: true},
+		{value: "1.2.3:8080:3000", wantErr: true},
+		{value: "::1:8080:3000", wantErr: true},
+		{value: "[::1]:3000", wantErr: true},
+		{value: "[nope]:8080:3000", wantErr: true},
+		{value: "[::1:8080:3000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParsePortMapping(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePortMapping(%q) error = %v, want error %t", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePortMapping(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+			if err == nil && got.String() != tt.wantString {
+				t.Errorf("ParsePortMapping(%q).String() = %q, want %q", tt.value, got.String(), tt.wantString)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package cmdrunner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/ashishb/asb/src/asb/internal/config"
+)
+
+// Files of the working directory that configure asb itself, always mounted as read-only, else a tool
+// could e.g. add a mount to the project config or stop masking the secrets for the next run.
+// The missing ones get an empty read-only file, so that the tool cannot create them either.
+var _asbConfigFileNames = []string{config.ProjectFileName, _asbIgnoreFileName, config.LockFileName}
+
+// Git directory of the working directory, mounted as read-only unless SetAllowGitWrite is set, as a tool
+// that can write to it can add a hook that runs on the host at the next commit, or delete the history
+const _gitDirName = ".git"
+
+// SetAllowGitWrite lets the tool write to the .git directory of a read-write working directory, e.g. to commit
+func SetAllowGitWrite(allowGitWrite bool) Option {
+	return func(c *Config) {
+		c.allowGitWrite = allowGitWrite
+	}
+}
+
+// AddReadOnlyPaths adds paths of the working directory, relative to it, that are mounted as read-only
+// even if the working directory is read-write, e.g. ".github/workflows"
+func AddReadOnlyPaths(paths []string) Option {
+	return func(c *Config) {
+		c.readOnlyPaths = append(c.readOnlyPaths, paths...)
+	}
+}
+
+// getReadOnlyPathMounts returns the read-only mounts of the protected paths, on top of the read-write
+// working directory. Paths that do not exist are skipped, except for the asb config files.
+func (c Config) getReadOnlyPathMounts() ([]Mount, error) {
+	if !c.mountWorkingDirRW {
+		return nil, nil
+	}
+
+	paths := slices.Concat(_asbConfigFileNames, c.readOnlyPaths)
+	if !c.allowGitWrite {
+		paths = slices.Concat([]string{_gitDirName}, paths)
+	}
+
+	mounts := make([]Mount, 0, len(paths))
+	for _, path := range paths {
+		absPath, ok, err := c.resolveWorkingDirPath(path)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		source := absPath
+		if _, err = os.Stat(absPath); errors.Is(err, os.ErrNotExist) {
+			if !slices.Contains(_asbConfigFileNames, path) {
+				continue
+			}
+			if source, err = writeEmptyFile(); err != nil {
+				return nil, err
+			}
+		}
+
+		mount := Mount{
+			Type:     MountTypeBind,
+			Source:   source,
+			Target:   absPath,
+			ReadOnly: true,
+			purpose:  _mountPurposeReadOnlyPath,
+		}
+		if !slices.Contains(mounts, mount) {
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts, nil
+}
+
+// getMissingAsbConfigFiles returns the asb config files that do not exist in a read-write working directory
+func (c Config) getMissingAsbConfigFiles() []string {
+	if !c.mountWorkingDirRW {
+		return nil
+	}
+
+	missing := make([]string, 0, len(_asbConfigFileNames))
+	for _, name := range _asbConfigFileNames {
+		path := filepath.Join(c.workingDir, name)
+		if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+			missing = append(missing, path)
+		}
+	}
+	return missing
+}
+
+// removeMountPointFiles removes the empty files that the backend created on the host as the mount points
+// of the missing asb config files
+func removeMountPointFiles(paths []string) {
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() != 0 {
+			continue
+		}
+		if err = os.Remove(path); err != nil {
+			log.Warn().
+				Err(err).
+				Str("path", path).
+				Msg("Failed to remove the mount point of the missing file")
+		}
+	}
+}
+
+// resolveWorkingDirPath returns the absolute path of a path of the working directory, relative to it,
+// and whether it can be mounted over, i.e. it is inside the working directory, also once its symlinks
+// are resolved, and is not a symlink itself. A path that does not exist can be mounted over once it is created.
+func (c Config) resolveWorkingDirPath(path string) (string, bool, error) {
+	absPath, err := getAbsolutePath(c.workingDir, path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get absolute path of %s: %w", path, err)
+	}
+	if !isSubPath(c.workingDir, absPath) {
+		log.Debug().
+			Str("path", absPath).
+			Msg("Path is not inside the working directory, skipping")
+		return "", false, nil
+	}
+
+	info, err := os.Lstat(absPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", false, fmt.Errorf("failed to check %s: %w", absPath, err)
+	}
+	if err == nil && info.Mode()&os.ModeSymlink != 0 {
+		// Mounting over a symlink mounts over its target inside the container, which can be anywhere
+		log.Warn().
+			Str("path", absPath).
+			Msg("Not mounting over the symlink, add the path it points to instead")
+		return "", false, nil
+	}
+
+	// A parent directory can be a symlink as well, e.g. "docs" pointing to the home directory
+	inside, err := isInsideWorkingDirOnceResolved(c.workingDir, absPath)
+	if err != nil {
+		return "", false, err
+	}
+	if !inside {
+		log.Warn().
+			Str("path", absPath).
+			Msg("Not mounting over the path, as it is outside the working directory once its symlinks are resolved")
+		return "", false, nil
+	}
+	return absPath, true, nil
+}
+
+// isInsideWorkingDirOnceResolved returns true if the path is inside the working directory once the symlinks
+// of both are resolved. Only the part of the path that exists is resolved.
+func isInsideWorkingDirOnceResolved(workingDir string, path string) (bool, error) {
+	realWorkingDir, err := filepath.EvalSymlinks(workingDir)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve symlinks of %s: %w", workingDir, err)
+	}
+
+	realPath, err := evalExistingSymlinks(path)
+	if err != nil {
+		return false, err
+	}
+	return isSubPath(realWorkingDir, realPath), nil
+}
+
+// evalExistingSymlinks resolves the symlinks of the longest existing prefix of the absolute path,
+// and appends the rest of the path to it
+func evalExistingSymlinks(path string) (string, error) {
+	existing, rest := path, ""
+	for {
+		_, err := os.Lstat(existing)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to check %s: %w", existing, err)
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return path, nil
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+
+	realPath, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve symlinks of %s: %w", existing, err)
+	}
+	return filepath.Join(realPath, rest), nil
+}
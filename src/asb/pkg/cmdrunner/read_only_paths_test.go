@@ -0,0 +1,66 @@
+package cmdrunner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGetReadOnlyPathMountsOfMissingConfigFiles(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	workingDir := t.TempDir()
+	projectConfig := filepath.Join(workingDir, ".asb.yaml")
+	if err := os.WriteFile(projectConfig, []byte("read-only: true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{workingDir: workingDir, mountWorkingDirRW: true, readOnlyPaths: []string{"docs"}}
+	mounts, err := config.getReadOnlyPathMounts()
+	if err != nil {
+		t.Fatalf("getReadOnlyPathMounts() failed: %v", err)
+	}
+
+	emptyFile, err := writeEmptyFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The missing .git and docs are skipped, the missing asb config files get the empty file
+	want := []Mount{
+		{Type: MountTypeBind, Source: projectConfig, Target: projectConfig, ReadOnly: true},
+		{Type: MountTypeBind, Source: emptyFile, Target: filepath.Join(workingDir, ".asbignore"), ReadOnly: true},
+		{Type: MountTypeBind, Source: emptyFile, Target: filepath.Join(workingDir, "asb.lock"), ReadOnly: true},
+	}
+	for i := range mounts {
+		mounts[i].purpose = ""
+	}
+	if !slices.Equal(mounts, want) {
+		t.Errorf("getReadOnlyPathMounts() = %+v, want %+v", mounts, want)
+	}
+
+	missing := config.getMissingAsbConfigFiles()
+	wantMissing := []string{filepath.Join(workingDir, ".asbignore"), filepath.Join(workingDir, "asb.lock")}
+	if !slices.Equal(missing, wantMissing) {
+		t.Errorf("getMissingAsbConfigFiles() = %q, want %q", missing, wantMissing)
+	}
+}
+
+func TestRemoveMountPointFiles(t *testing.T) {
+	dir := t.TempDir()
+	mountPoint := filepath.Join(dir, ".asbignore")
+	written := filepath.Join(dir, "asb.lock")
+	if err := os.WriteFile(mountPoint, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(written, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	removeMountPointFiles([]string{mountPoint, written, filepath.Join(dir, ".asb.yaml")})
+	if _, err := os.Stat(mountPoint); !os.IsNotExist(err) {
+		t.Errorf("Empty mount point %s was not removed", mountPoint)
+	}
+	if _, err := os.Stat(written); err != nil {
+		t.Errorf("Non-empty file %s was removed: %v", written, err)
+	}
+}
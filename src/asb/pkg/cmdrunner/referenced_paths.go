@@ -0,0 +1,191 @@
+package cmdrunner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Flags that take a path with most tools, in addition to the tool's own path flags.
+// Short flags like "-o" mean different things to different tools, so they are only in the tools' path flags.
+var _commonPathFlags = []string{
+	"--output", "--output-file", "--output-dir",
+	"--out", "--out-file", "--out-dir", "--outfile", "--outdir",
+	"--config", "--config-file",
+}
+
+// referencedPath is a host path referenced by the args, which gets mounted into the sandbox
+type referencedPath struct {
+	source string // Real path on the host, with the symlinks resolved
+	target string // Path inside the container
+}
+
+// pathCandidate is an arg, or the value of a flag, that might be a path
+type pathCandidate struct {
+	value     string
+	isFlagArg bool // Value of a path flag, which can be an output path that does not exist yet
+}
+
+// findReferencedPaths returns the paths outside the working directory referenced by the args,
+// e.g. "../data", "~/notes", "--config=/etc/x.yaml" or "--output /tmp/report.json".
+// An output path that does not exist yet is replaced with its parent directory.
+func (c Config) findReferencedPaths() []referencedPath {
+	workingDir, err := filepath.Abs(c.workingDir)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("workingDir", c.workingDir).
+			Msg("Failed to get absolute path of working directory, skipping referenced paths")
+		return nil
+	}
+
+	// Paths inside the real working directory are mounted as well, e.g. when it is below a symlink
+	realWorkingDir, err := filepath.EvalSymlinks(workingDir)
+	if err != nil {
+		realWorkingDir = workingDir
+	}
+
+	paths := make([]referencedPath, 0)
+	for _, candidate := range getPathCandidates(c.args, slices.Concat(_commonPathFlags, c.tool.PathFlags)) {
+		referenced, found := resolveReferencedPath(workingDir, realWorkingDir, candidate)
+		if found && !slices.Contains(paths, referenced) {
+			paths = append(paths, referenced)
+		}
+	}
+	return paths
+}
+
+// getReferencedFiles returns the host paths of the referenced paths that get mounted
+func (c Config) getReferencedFiles() []string {
+	files := make([]string, 0)
+	for _, referenced := range c.referencedPaths {
+		files = append(files, referenced.source)
+	}
+	return files
+}
+
+// getPathCandidates returns the args that look like paths, and the values of the flags that take paths,
+// both as "--flag value" and "--flag=value"
+func getPathCandidates(args []string, pathFlags []string) []pathCandidate {
+	candidates := make([]pathCandidate, 0)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if slices.Contains(pathFlags, arg) && i+1 < len(args) {
+			i++
+			candidates = append(candidates, pathCandidate{value: args[i], isFlagArg: true})
+			continue
+		}
+
+		if flag, value, found := strings.Cut(arg, "="); found && strings.HasPrefix(flag, "-") {
+			if isPathFlag := slices.Contains(pathFlags, flag); isPathFlag || looksLikePath(value) {
+				candidates = append(candidates, pathCandidate{value: value, isFlagArg: isPathFlag})
+			}
+			continue
+		}
+
+		if looksLikePath(arg) {
+			candidates = append(candidates, pathCandidate{value: arg})
+		}
+	}
+	return candidates
+}
+
+// looksLikePath returns true for absolute paths, paths relative to the home directory and
+// relative paths with more than one component, e.g. "./sub/../../x".
+// URLs and package specs like "@types/node" or "github:user/repo" are not paths.
+func looksLikePath(value string) bool {
+	if value == "" || strings.HasPrefix(value, "-") || strings.HasPrefix(value, "@") {
+		return false
+	}
+	if scheme, _, found := strings.Cut(value, ":"); found && !strings.ContainsAny(scheme, `/\`) && len(scheme) > 1 {
+		// E.g. "https://example.com/x" or "github:user/repo", but not "C:\x" on Windows
+		return false
+	}
+	return value == "~" || value == ".." || strings.HasPrefix(value, "~/") || strings.ContainsRune(value, '/') ||
+		strings.ContainsRune(value, filepath.Separator)
+}
+
+// resolveReferencedPath returns the mount for the candidate, if it is outside the working directory
+func resolveReferencedPath(workingDir string, realWorkingDir string, candidate pathCandidate) (referencedPath, bool) {
+	absPath, err := getAbsolutePath(workingDir, candidate.value)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("path", candidate.value).
+			Msg("Skipping referenced path")
+		return referencedPath{}, false
+	}
+
+	realPath, err := filepath.EvalSymlinks(absPath)
+	if errors.Is(err, os.ErrNotExist) && candidate.isFlagArg {
+		// An output path, e.g. "--output /tmp/report.json", the tool creates it in its parent directory
+		absPath = filepath.Dir(absPath)
+		realPath, err = filepath.EvalSymlinks(absPath)
+		if err == nil && !isSubPath(realWorkingDir, realPath) && isTooBroadToMount(realPath, realWorkingDir) {
+			log.Warn().
+				Str("path", candidate.value).
+				Str("parent", realPath).
+				Msg("Not mounting the parent directory of the output path, as it is too broad. " +
+					"Create the output directory first, or write inside the working directory")
+			return referencedPath{}, false
+		}
+	}
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("path", absPath).
+			Msg("Referenced file/directory does not exist, skipping mount")
+		return referencedPath{}, false
+	}
+
+	if isSubPath(workingDir, realPath) || isSubPath(realWorkingDir, realPath) {
+		// Already mounted as part of the working directory
+		return referencedPath{}, false
+	}
+
+	if isSubPath(workingDir, absPath) {
+		// A symlink inside the working directory pointing outside of it, mount the target
+		// where the symlink points to, so that it resolves inside the container as well
+		return referencedPath{source: realPath, target: realPath}, true
+	}
+	return referencedPath{source: realPath, target: absPath}, true
+}
+
+// isTooBroadToMount returns true for directories that must not be mounted just because an output file
+// is written to them: the home directory, the ancestors of it and of the working directory, e.g. the root,
+// and the directories shared by all users, e.g. /tmp
+func isTooBroadToMount(dir string, workingDir string) bool {
+	if isSubPath(dir, workingDir) {
+		return true
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		if realHomeDir, err := filepath.EvalSymlinks(homeDir); err == nil && isSubPath(dir, realHomeDir) {
+			return true
+		}
+	}
+
+	// World-writable directories with the sticky bit, like /tmp, hold the files of the other users
+	info, err := os.Stat(dir)
+	return err == nil && info.Mode()&os.ModeSticky != 0 && info.Mode().Perm()&0o002 != 0
+}
+
+// getAbsolutePath expands a leading "~" to the home directory and makes the path absolute,
+// relative to baseDir
+func getAbsolutePath(baseDir string, path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = expandHomeDir(path, homeDir)
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	return filepath.Clean(path), nil
+}
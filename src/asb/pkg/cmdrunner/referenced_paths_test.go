@@ -0,0 +1,196 @@
+package cmdrunner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLooksLikePath(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{value: "/etc/hosts", want: true},
+		{value: "~", want: true},
+		{value: "~/notes", want: true},
+		{value: "..", want: true},
+		{value: "../data", want: true},
+		{value: "./sub/../../x", want: true},
+		{value: "sub/file.txt", want: true},
+		{value: "./a:b", want: true},
+		{value: "file.txt", want: false},
+		{value: ".", want: false},
+		{value: "", want: false},
+		{value: "-o", want: false},
+		{value: "--output=/tmp/x", want: false},
+		{value: "@types/node", want: false},
+		{value: "https://example.com/x", want: false},
+		{value: "github:user/repo", want: false},
+		{value: "git+ssh://git@example.com/repo.git", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := looksLikePath(tt.value); got != tt.want {
+				t.Errorf("looksLikePath(%q) = %t, want %t", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPathCandidates(t *testing.T) {
+	pathFlags := []string{"--output", "--config", "-o"}
+	tests := []struct {
+		name string
+		args []string
+		want []pathCandidate
+	}{
+		{
+			name: "flag and value",
+			args: []string{"--output", "report.json"},
+			want: []pathCandidate{{value: "report.json", isFlagArg: true}},
+		},
+		{
+			name: "flag with equals",
+			args: []string{"--config=../c.yaml"},
+			want: []pathCandidate{{value: "../c.yaml", isFlagArg: true}},
+		},
+		{
+			name: "short flag of the tool",
+			args: []string{"-o", "/tmp/out/r.txt"},
+			want: []pathCandidate{{value: "/tmp/out/r.txt", isFlagArg: true}},
+		},
+		{
+			name: "other flag with a path value",
+			args: []string{"--cache=../cache"},
+			want: []pathCandidate{{value: "../cache"}},
+		},
+		{
+			name: "other flag with a non-path value",
+			args: []string{"--level=info"},
+			want: []pathCandidate{},
+		},
+		{
+			name: "path flag without a value",
+			args: []string{"--output"},
+			want: []pathCandidate{},
+		},
+		{
+			name: "positional args",
+			args: []string{"install", "@types/node", "../lib", "https://example.com/x.tgz", "~/notes"},
+			want: []pathCandidate{{value: "../lib"}, {value: "~/notes"}},
+		},
+		{
+			name: "value of a path flag that does not look like a path",
+			args: []string{"--output", "report.json", "lint"},
+			want: []pathCandidate{{value: "report.json", isFlagArg: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPathCandidates(tt.args, pathFlags); !slices.Equal(got, tt.want) {
+				t.Errorf("getPathCandidates(%q) = %+v, want %+v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveReferencedPath(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	workingDir := filepath.Join(root, "work")
+	outside := filepath.Join(root, "outside")
+	homeDir := filepath.Join(root, "home")
+	shared := filepath.Join(root, "shared")
+	for _, dir := range []string{workingDir, outside, homeDir, shared} {
+		if err = os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.Chmod(shared, 0o777|os.ModeSticky); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(outside, "data.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(workingDir, "inside.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(outside, filepath.Join(workingDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", homeDir)
+
+	tests := []struct {
+		name      string
+		candidate pathCandidate
+		want      referencedPath
+		wantFound bool
+	}{
+		{
+			name:      "existing path outside",
+			candidate: pathCandidate{value: "../outside/data.txt"},
+			want:      referencedPath{source: filepath.Join(outside, "data.txt"), target: filepath.Join(outside, "data.txt")},
+			wantFound: true,
+		},
+		{
+			name:      "path inside",
+			candidate: pathCandidate{value: "./inside.txt"},
+		},
+		{
+			name:      "symlink inside pointing outside",
+			candidate: pathCandidate{value: "link/data.txt"},
+			want:      referencedPath{source: filepath.Join(outside, "data.txt"), target: filepath.Join(outside, "data.txt")},
+			wantFound: true,
+		},
+		{
+			name:      "missing path",
+			candidate: pathCandidate{value: "../outside/missing.txt"},
+		},
+		{
+			name:      "missing output path",
+			candidate: pathCandidate{value: "../outside/report.json", isFlagArg: true},
+			want:      referencedPath{source: outside, target: outside},
+			wantFound: true,
+		},
+		{
+			name:      "missing output path in the working directory",
+			candidate: pathCandidate{value: "report.json", isFlagArg: true},
+		},
+		{
+			name:      "missing output path in an ancestor of the working directory",
+			candidate: pathCandidate{value: "../report.json", isFlagArg: true},
+		},
+		{
+			name:      "missing output path in the root",
+			candidate: pathCandidate{value: "/report.json", isFlagArg: true},
+		},
+		{
+			name:      "missing output path in the home directory",
+			candidate: pathCandidate{value: "~/report.json", isFlagArg: true},
+		},
+		{
+			name:      "missing output path in a shared directory",
+			candidate: pathCandidate{value: "../shared/report.json", isFlagArg: true},
+		},
+		{
+			name:      "missing output path in a missing directory",
+			candidate: pathCandidate{value: "../outside/missing/report.json", isFlagArg: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := resolveReferencedPath(workingDir, workingDir, tt.candidate)
+			if got != tt.want || found != tt.wantFound {
+				t.Errorf("resolveReferencedPath(%+v) = %+v, %t, want %+v, %t",
+					tt.candidate, got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package cmdrunner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Memory sizes like "512m" or "1.5g", the unit can be followed by "b" and is bytes if omitted
+var _memorySizeRegex = regexp.MustCompile(`^([0-9]*\.?[0-9]+)([kmgt]?)b?$`)
+
+// Exit code of a process killed by SIGKILL, which is what the kernel's OOM killer sends
+const _sigKillExitCode = 128 + 9
+
+// ExitState describes how the sandbox exited
+type ExitState struct {
+	ExitCode  int
+	OOMKilled *bool // Whether the kernel killed it for exceeding the memory limit, nil if the backend cannot tell
+	PeakPids  int64 // Highest number of processes and threads seen while it ran, zero if the backend cannot tell
+}
+
+// ResourceLimits limit the resources available to the sandbox, zero values mean no limit
+type ResourceLimits struct {
+	MemoryBytes int64         // Memory limit, swap is disabled when this is set
+	CPUs        float64       // Number of CPUs, e.g. 1.5
+	PidsLimit   int64         // Maximum number of processes and threads
+	Timeout     time.Duration // Wall-clock time after which the sandbox is killed
+}
+
+// SetResourceLimits overrides the tool's default limits with the non-zero values in limits
+func SetResourceLimits(limits ResourceLimits) Option {
+	return func(c *Config) {
+		if limits.MemoryBytes != 0 {
+			c.resourceLimits.MemoryBytes = limits.MemoryBytes
+		}
+		if limits.CPUs != 0 {
+			c.resourceLimits.CPUs = limits.CPUs
+		}
+		if limits.PidsLimit != 0 {
+			c.resourceLimits.PidsLimit = limits.PidsLimit
+		}
+		if limits.Timeout != 0 {
+			c.resourceLimits.Timeout = limits.Timeout
+		}
+	}
+}
+
+// ParseMemorySize parses sizes like "512m" or "2g" into bytes, same as "docker run --memory"
+func ParseMemorySize(value string) (int64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	multipliers := map[string]int64{
+		"":  1,
+		"k": 1 << 10,
+		"m": 1 << 20,
+		"g": 1 << 30,
+		"t": 1 << 40,
+	}
+
+	// ParseFloat alone would accept e.g. "1e3", "inf" or "nan"
+	match := _memorySizeRegex.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid memory size %q, expected a size like 512m or 2g", value)
+	}
+
+	size, err := strconv.ParseFloat(match[1], 64)
+	sizeBytes := size * float64(multipliers[match[2]])
+	if err != nil || sizeBytes < 1 || sizeBytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid memory size %q, expected a size like 512m or 2g", value)
+	}
+	return int64(sizeBytes), nil
+}
+
+// describeKill returns a human-readable reason if the sandbox was killed, or failed to create processes,
+// because of a limit, else an empty string
+func (l ResourceLimits) describeKill(state ExitState) string {
+	if l.MemoryBytes > 0 {
+		switch {
+		case state.OOMKilled != nil && *state.OOMKilled:
+			return fmt.Sprintf("sandbox was killed for exceeding the memory limit of %d MiB, raise it via --memory",
+				l.MemoryBytes>>20)
+		case state.OOMKilled == nil && state.ExitCode == _sigKillExitCode:
+			// E.g. Podman, which removes the container before it can be inspected
+			return fmt.Sprintf("sandbox was killed with SIGKILL, most likely for exceeding the memory limit of %d MiB, "+
+				"raise it via --memory", l.MemoryBytes>>20)
+		}
+	}
+	if l.PidsLimit > 0 && state.PeakPids >= l.PidsLimit {
+		return fmt.Sprintf("sandbox reached the limit of %d processes and threads, so creating more failed, "+
+			"raise it via --pids-limit", l.PidsLimit)
+	}
+	return ""
+}
+
+// capCPUs lowers the CPU limit to the number of CPUs of the container engine, which refuses a higher one.
+// E.g. the default of a tool can be higher than the CPUs of the Docker Desktop VM.
+func (l ResourceLimits) capCPUs(ctx context.Context, backend Backend) ResourceLimits {
+	if l.CPUs == 0 {
+		return l
+	}
+
+	info, err := backend.Info(ctx)
+	if err != nil || info.CPUs <= 0 || l.CPUs <= float64(info.CPUs) {
+		return l
+	}
+
+	log.Debug().
+		Float64("cpus", l.CPUs).
+		Int("available", info.CPUs).
+		Msg("Lowering the CPU limit to the CPUs available to the containers")
+	l.CPUs = float64(info.CPUs)
+	return l
+}
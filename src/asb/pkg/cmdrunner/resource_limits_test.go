@@ -0,0 +1,50 @@
+package cmdrunner
+
+import (
+	"testing"
+)
+
+func TestParseMemorySize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "512", want: 512},
+		{value: "512b", want: 512},
+		{value: "64k", want: 64 << 10},
+		{value: "512m", want: 512 << 20},
+		{value: "512M", want: 512 << 20},
+		{value: "2g", want: 2 << 30},
+		{value: "2gb", want: 2 << 30},
+		{value: " 2G ", want: 2 << 30},
+		{value: "1.5g", want: 3 << 29},
+		{value: ".5m", want: 1 << 19},
+		{value: "1t", want: 1 << 40},
+		{value: "", wantErr: true},
+		{value: "b", wantErr: true},
+		{value: "g", wantErr: true},
+		{value: "0", wantErr: true},
+		{value: "0m", wantErr: true},
+		{value: "0.5", wantErr: true},
+		{value: "-1g", wantErr: true},
+		{value: "2x", wantErr: true},
+		{value: "2 g", wantErr: true},
+		{value: "1e3", wantErr: true},
+		{value: "inf", wantErr: true},
+		{value: "nan", wantErr: true},
+		{value: "10000000t", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseMemorySize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMemorySize(%q) error = %v, want error %t", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMemorySize(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package cmdrunner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	_ "embed"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Seccomp profile that allows only the syscalls of Docker's default profile, minus the kernel, namespace,
+// keyring, io_uring and tracing related ones, which asb additionally denies. It replaces Docker's default profile.
+//
+//go:embed seccomp.json
+var _seccompProfile []byte
+
+// Capabilities that the processes running as root need to work with files owned by
+// other users, e.g. the bind-mounted working directory
+var _rootBaseCapabilities = []string{"CHOWN", "DAC_OVERRIDE", "FOWNER"}
+
+// SetInsecure disables the hardened security profile, i.e. dropping capabilities,
+// no-new-privileges and the seccomp profile
+func SetInsecure(insecure bool) Option {
+	return func(c *Config) {
+		c.insecure = insecure
+	}
+}
+
+// SetReadOnlyRootFS mounts the container's root filesystem as read-only,
+// with a tmpfs for /tmp and the home directory
+func SetReadOnlyRootFS(readOnlyRootFS bool) Option {
+	return func(c *Config) {
+		c.readOnlyRootFS = readOnlyRootFS
+	}
+}
+
+func (c Config) getCapabilities() []string {
+	if !c.getContainerUser().isRoot() {
+		return nil
+	}
+	return slices.Concat(_rootBaseCapabilities, c.tool.RootCapabilities)
+}
+
+func (c Config) addSecurityToSpec(spec *ContainerSpec) error {
+	if c.readOnlyRootFS {
+		spec.ReadOnlyRootFS = true
+		tmpfsDirs := []string{"/tmp", "/var/tmp"}
+		if c.getContainerUser().isRoot() {
+			tmpfsDirs = append(tmpfsDirs, _rootHomeDir)
+		}
+		for _, dir := range tmpfsDirs {
+			spec.Mounts = append(spec.Mounts, Mount{
+				Type:    MountTypeTmpfs,
+				Target:  dir,
+				Options: "rw,exec,nosuid,nodev",
+				purpose: _mountPurposeScratch,
+			})
+		}
+	}
+
+	if c.insecure {
+		log.Warn().
+			Msg("Running without the hardened security profile")
+		return nil
+	}
+
+	seccompProfileFile, err := writeSeccompProfile()
+	if err != nil {
+		return err
+	}
+
+	spec.CapDrop = []string{"ALL"}
+	spec.CapAdd = c.getCapabilities()
+	spec.SecurityOpts = []string{"no-new-privileges", "seccomp=" + seccompProfileFile}
+	return nil
+}
+
+// writeSeccompProfile writes the bundled seccomp profile to the user cache directory,
+// as the docker and podman CLIs only accept a profile file, and returns its path
+func writeSeccompProfile() (string, error) {
+	profileFile, err := getSeccompProfileFile()
+	if err != nil {
+		return "", err
+	}
+
+	// The file is reused only if it is unchanged, as anything running as the user can modify it
+	if content, err := os.ReadFile(profileFile); err == nil && bytes.Equal(content, _seccompProfile) {
+		return profileFile, nil
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to check seccomp profile %s: %w", profileFile, err)
+	}
+
+	if err = writeFileAtomic(profileFile, _seccompProfile, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write seccomp profile: %w", err)
+	}
+	return profileFile, nil
+}
+
+// getSeccompProfileFile returns the path of the bundled seccomp profile in the user cache directory
+func getSeccompProfileFile() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+
+	// The hash in the name ensures that an updated profile gets written to a new file
+	hash := sha256.Sum256(_seccompProfile)
+	return filepath.Join(cacheDir, "asb", "seccomp-"+hex.EncodeToString(hash[:6])+".json"), nil
+}
@@ -0,0 +1,171 @@
+package cmdrunner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/ashishb/asb/src/asb/internal/config"
+)
+
+// Host paths that are never mounted, nor any directory containing them, as they hold credentials
+// or control the host. "~/" is replaced with the home directory.
+var _defaultSensitivePaths = []string{
+	"~/.ssh",
+	"~/.aws",
+	"~/.azure",
+	"~/.gnupg",
+	"~/.kube",
+	"~/.docker",
+	"~/.config/gcloud",
+	"~/.password-store",
+	"~/.netrc",
+	"~/.git-credentials",
+	"/var/run/docker.sock",
+	"/run/docker.sock",
+	"/run/podman",
+	"/etc/shadow",
+	"/etc/sudoers",
+}
+
+// Directories that are never mounted themselves, but whose subdirectories can be.
+// They are covered by _defaultSensitivePaths as well, but this keeps them denied if those are allowed.
+var _sensitiveRootPaths = []string{"/", "~"}
+
+// AddSensitivePaths adds paths that must never be mounted to the built-in ones, "~/" is the home directory
+func AddSensitivePaths(paths []string) Option {
+	return func(c *Config) {
+		c.sensitivePaths = append(c.sensitivePaths, paths...)
+	}
+}
+
+// AllowSensitivePaths removes paths from the built-in sensitive paths, e.g. "~/.docker"
+func AllowSensitivePaths(paths []string) Option {
+	return func(c *Config) {
+		c.allowedSensitivePaths = append(c.allowedSensitivePaths, paths...)
+	}
+}
+
+// checkSensitiveMounts refuses to mount the working directory or an extra mount that exposes
+// a sensitive path. The referenced paths found in the args are not mounted instead, with a warning,
+// as they can come from anywhere, e.g. an arg generated by a coding agent.
+func (c Config) checkSensitiveMounts() (Config, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return c, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	sensitivePaths := c.getSensitivePaths(homeDir)
+	if c.mountWorkingDirRW || c.mountWorkingDirRO {
+		workingDir, err := filepath.Abs(c.workingDir)
+		if err != nil {
+			return c, fmt.Errorf("failed to get absolute path of %s: %w", c.workingDir, err)
+		}
+		if sensitive, found := findExposedSensitivePath(workingDir, sensitivePaths, homeDir); found {
+			return c, &SensitivePathError{Source: workingDir, Sensitive: sensitive, Purpose: _mountPurposeWorkingDir}
+		}
+	}
+
+	for _, mount := range c.extraMounts {
+		if sensitive, found := findExposedSensitivePath(mount.source, sensitivePaths, homeDir); found {
+			return c, &SensitivePathError{Source: mount.source, Sensitive: sensitive, Purpose: _mountPurposeConfig}
+		}
+	}
+
+	agentMounts, err := getCodingAgentMounts(c)
+	if err != nil {
+		return c, err
+	}
+	for _, mount := range agentMounts {
+		if sensitive, found := findExposedSensitivePath(mount.Source, sensitivePaths, homeDir); found {
+			return c, &SensitivePathError{Source: mount.Source, Sensitive: sensitive, Purpose: mount.purpose}
+		}
+	}
+
+	c.referencedPaths = make([]referencedPath, 0)
+	for _, referenced := range c.findReferencedPaths() {
+		if sensitive, found := findExposedSensitivePath(referenced.source, sensitivePaths, homeDir); found {
+			log.Warn().
+				Str("path", referenced.source).
+				Str("sensitivePath", sensitive).
+				Msg("Not mounting the referenced path, as it exposes a sensitive path")
+			continue
+		}
+		c.referencedPaths = append(c.referencedPaths, referenced)
+	}
+	return c, nil
+}
+
+// getSensitivePaths returns the absolute sensitive paths, with the allowed ones removed
+func (c Config) getSensitivePaths(homeDir string) []string {
+	allowed := make([]string, 0, len(c.allowedSensitivePaths))
+	for _, path := range c.allowedSensitivePaths {
+		allowed = append(allowed, expandHomeDir(path, homeDir))
+	}
+
+	paths := getAsbDirs()
+	for _, path := range slices.Concat(_defaultSensitivePaths, c.sensitivePaths) {
+		path = expandHomeDir(path, homeDir)
+		if !slices.Contains(allowed, path) && !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// getAsbDirs returns the directories of asb itself, i.e. the user config, the cache with the audit log
+// and the policy. They are sensitive even if allowed, else the sandbox could e.g. set allow-sensitive-paths
+// or tool-registry in the user config for the next runs.
+func getAsbDirs() []string {
+	dirs := []string{filepath.Dir(config.PolicyFile)}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(configDir, "asb"))
+	}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		dirs = append(dirs, filepath.Join(cacheDir, "asb"))
+	}
+	return dirs
+}
+
+// findExposedSensitivePath returns the sensitive path that mounting source exposes, i.e. source
+// is the sensitive path, is inside it or contains it. Symlinks are resolved on both sides.
+func findExposedSensitivePath(source string, sensitivePaths []string, homeDir string) (string, bool) {
+	sources := withRealPath(filepath.Clean(source))
+	for _, rootPath := range _sensitiveRootPaths {
+		rootPath = expandHomeDir(rootPath, homeDir)
+		for _, candidate := range withRealPath(rootPath) {
+			if slices.Contains(sources, candidate) {
+				return rootPath, true
+			}
+		}
+	}
+
+	for _, sensitive := range sensitivePaths {
+		for _, candidate := range withRealPath(sensitive) {
+			if slices.ContainsFunc(sources, func(source string) bool { return pathsOverlap(source, candidate) }) {
+				return sensitive, true
+			}
+		}
+	}
+	return "", false
+}
+
+// withRealPath returns the path, and the path with its symlinks resolved if it exists and differs
+func withRealPath(path string) []string {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil || realPath == path {
+		return []string{path}
+	}
+	return []string{path, realPath}
+}
+
+func expandHomeDir(path string, homeDir string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+	}
+	return filepath.Clean(path)
+}
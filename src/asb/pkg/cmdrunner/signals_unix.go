@@ -0,0 +1,18 @@
+//go:build !windows
+
+package cmdrunner
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Signals forwarded to the container, the rest are either handled by the Go runtime or meaningless for it
+var _forwardedSignals = []os.Signal{
+	syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2,
+}
+
+func notifyResize(resizes chan<- os.Signal) {
+	signal.Notify(resizes, syscall.SIGWINCH)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package cmdrunner
+
+import (
+	"os"
+	"syscall"
+)
+
+// Signals forwarded to the container
+var _forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+
+// notifyResize is a no-op, as Windows has no signal for terminal resizes
+func notifyResize(chan<- os.Signal) {}
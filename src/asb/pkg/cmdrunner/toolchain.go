@@ -0,0 +1,66 @@
+package cmdrunner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/ashishb/asb/src/asb/internal/toolchain"
+)
+
+// SetToolVersion requests a version of the tool's toolchain, e.g. "20" or ">=3.10".
+// It overrides the version requested by the project files, e.g. ".nvmrc".
+func SetToolVersion(version string) Option {
+	return func(c *Config) {
+		c.requestedToolVersion = version
+	}
+}
+
+// selectToolchainImage returns the config with the image of the toolchain version that is
+// requested either explicitly or by the project files
+func (c Config) selectToolchainImage() (Config, error) {
+	chain := c.tool.Toolchain
+	if chain == nil {
+		if c.requestedToolVersion != "" {
+			return c, fmt.Errorf("selecting the version is not supported for %s", c.cmdType)
+		}
+		return c, nil
+	}
+
+	requested, source := c.requestedToolVersion, "--tool-version"
+	if requested == "" {
+		var err error
+		if requested, source, err = toolchain.FindVersion(c.workingDir, chain.Files); err != nil {
+			return c, err
+		}
+	}
+	if requested == "" {
+		log.Debug().
+			Str("image", c.dockerBaseImage).
+			Msg("No toolchain version requested, using the default image")
+		return c, nil
+	}
+
+	version, err := toolchain.Select(requested, chain.Versions, chain.Precision)
+	if err != nil {
+		return c, fmt.Errorf("no %s image matches the version %q requested by %s: %w",
+			c.cmdType, requested, source, err)
+	}
+
+	c.dockerBaseImage = strings.ReplaceAll(chain.Image, "{version}", version)
+	c.toolVersion = ToolVersion{Requested: requested, Source: source, Selected: version}
+	log.Debug().
+		Str("requested", requested).
+		Str("source", source).
+		Str("image", c.dockerBaseImage).
+		Msg("Selected toolchain image")
+	return c, nil
+}
+
+// ToolVersion describes how the toolchain version was selected
+type ToolVersion struct {
+	Requested string `json:"requested"` // E.g. ">=3.10"
+	Source    string `json:"source"`    // File that requested the version, or "--tool-version"
+	Selected  string `json:"selected"`  // Version whose image is used, e.g. "3.13"
+}
@@ -0,0 +1,92 @@
+package cmdrunner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// SetMountWorkingDirWritablePaths mounts the working directory as read-only, except for the writable paths,
+// i.e. the tool's ones like "node_modules" and the ones added via AddWritablePaths, which are read-write.
+// This lets e.g. "npm ci" populate node_modules without being able to modify the source files.
+func SetMountWorkingDirWritablePaths(mountWritablePaths bool) Option {
+	return func(c *Config) {
+		if mountWritablePaths {
+			SetMountWorkingDirReadOnly(true)(c)
+		}
+		c.mountWritablePaths = mountWritablePaths
+	}
+}
+
+// AddWritablePaths adds paths of the working directory, relative to it, that are read-write
+// with SetMountWorkingDirWritablePaths, in addition to the tool's ones
+func AddWritablePaths(paths []string) Option {
+	return func(c *Config) {
+		c.writablePaths = append(c.writablePaths, paths...)
+	}
+}
+
+func (c Config) getWritablePaths() []string {
+	if !c.mountWritablePaths {
+		return nil
+	}
+	return slices.Concat(c.tool.WritablePaths, c.writablePaths)
+}
+
+// getWritablePathMounts returns the read-write mounts of the writable paths, on top of the read-only
+// working directory
+func (c Config) getWritablePathMounts() ([]Mount, error) {
+	mounts := make([]Mount, 0)
+	for _, path := range c.getWritablePaths() {
+		absPath, ok, err := c.resolveWorkingDirPath(path)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		mount := Mount{
+			Type:    MountTypeBind,
+			Source:  absPath,
+			Target:  absPath,
+			purpose: _mountPurposeWritablePath,
+		}
+		if !slices.Contains(mounts, mount) {
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts, nil
+}
+
+// createWritablePaths creates the writable paths that do not exist yet as directories, as a bind mount
+// needs an existing source, e.g. node_modules before the first "npm ci"
+func createWritablePaths(config Config) error {
+	mounts, err := config.getWritablePathMounts()
+	if err != nil {
+		return err
+	}
+
+	for _, mount := range mounts {
+		if _, err = os.Stat(mount.Source); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		// os.MkdirAll follows the symlinks of the parent directories, which could have been replaced since
+		// the mounts were resolved, e.g. by the previous run of the tool
+		inside, err := isInsideWorkingDirOnceResolved(config.workingDir, filepath.Dir(mount.Source))
+		if err != nil {
+			return err
+		}
+		if !inside {
+			return fmt.Errorf("not creating writable path %s, as it is outside the working directory %s "+
+				"once its symlinks are resolved", mount.Source, config.workingDir)
+		}
+		if err = os.MkdirAll(mount.Source, 0o755); err != nil {
+			return fmt.Errorf("failed to create writable path %s: %w", mount.Source, err)
+		}
+	}
+	return nil
+}
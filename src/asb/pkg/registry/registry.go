@@ -0,0 +1,358 @@
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	_ "embed"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ashishb/asb/src/asb/internal/toolchain"
+)
+
+// Registry of the tools shipped with asb
+//
+//go:embed tools.yaml
+var _defaultRegistry []byte
+
+// Values accepted for Tool.Network and Tool.DiskAccess, empty means the default
+var (
+	_networkTypes = []string{"", "host", "none", "bridge", "proxy"}
+	_diskAccesses = []string{"", DiskAccessReadWrite, DiskAccessReadOnly, DiskAccessWritablePaths, DiskAccessNone}
+)
+
+// Tool names used by asb itself, "run" is the command type of "asb run"
+var _reservedToolNames = []string{"run"}
+
+const (
+	DiskAccessReadWrite = "read-write"
+	DiskAccessReadOnly  = "read-only"
+	DiskAccessNone      = "none"
+
+	// Working directory is read-only, except for the tool's writable paths
+	DiskAccessWritablePaths = "writable-paths"
+)
+
+var (
+	// Names are used as keys in the config and policy files, and in the container names
+	_toolNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_]*$`)
+	// Commands become subcommands of asb
+	_commandRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	// Volume names become part of the backend's volume names
+	_volumeNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_.]*$`)
+	// Memory sizes like "512m" or "2g", same as "docker run --memory"
+	_memorySizeRegex = regexp.MustCompile(`(?i)^[0-9]*\.?[0-9]+[kmgt]?b?$`)
+)
+
+// Registry describes the tools asb can run and the cache volumes they use
+type Registry struct {
+	CacheVolumes []CacheVolume `yaml:"cache-volumes"`
+	Tools        []Tool        `yaml:"tools"`
+}
+
+// CacheVolume is a named volume used to persist a tool's cache across runs
+type CacheVolume struct {
+	Name   string `yaml:"name"`
+	Target string `yaml:"target"` // Mount point inside the container, "~/" is replaced with the home directory
+}
+
+// Tool is a command that asb can run inside the sandbox
+type Tool struct {
+	Name          string       `yaml:"name"`           // Key in the config and policy files, e.g. "python_uvx"
+	Command       string       `yaml:"command"`        // Subcommand of asb, e.g. "uvx"
+	Description   string       `yaml:"description"`    // Shown in the help
+	Image         string       `yaml:"image"`          // Container image to run the tool in
+	CommandPrefix []string     `yaml:"command-prefix"` // Prepended to the args, empty runs the args as is
+	ArgRewrites   []ArgRewrite `yaml:"arg-rewrites"`   // Applied to the args before adding the prefix
+
+	Caches         []string `yaml:"caches"`          // Names of the cache volumes to mount
+	AllowedDomains []string `yaml:"allowed-domains"` // Domains reachable with the "proxy" network
+	Network        string   `yaml:"network"`         // Default network, empty means "host"
+	DiskAccess     string   `yaml:"disk-access"`     // Default access to the working directory, empty means read-write
+	PidsLimit      int64    `yaml:"pids-limit"`      // Default limit on processes and threads, zero means no limit
+
+	Memory  string        `yaml:"memory"`  // Default memory limit, e.g. "4g", empty means no limit
+	CPUs    float64       `yaml:"cpus"`    // Default number of CPUs, e.g. 1.5, zero means no limit
+	Timeout time.Duration `yaml:"timeout"` // Default wall-clock limit, e.g. "30m", zero means no limit
+
+	// Flags whose value is a path, e.g. "--manifest-path", in addition to the common ones like "--output".
+	// Their paths are mounted even if they do not exist yet, by mounting the parent directory.
+	PathFlags []string `yaml:"path-flags"`
+	// Paths of the working directory, relative to it, that the tool writes to, e.g. "node_modules".
+	// They are the only read-write ones with the "writable-paths" disk access.
+	WritablePaths []string `yaml:"writable-paths"`
+
+	// Capabilities added back when running as root
+	RootCapabilities []string `yaml:"root-capabilities"`
+	// Whether to mount the config of coding agents like Claude Code from the host
+	MountAgentConfigs bool `yaml:"mount-agent-configs"`
+	// Whether to leave the tool out of the generated commands
+	Hidden bool `yaml:"hidden"`
+
+	// How to pick the image for the toolchain version the project asks for, nil means Image is always used
+	Toolchain *Toolchain `yaml:"toolchain"`
+}
+
+// Toolchain selects the image for a version of the tool's language, e.g. "node:20-bookworm" for Node 20
+type Toolchain struct {
+	Image     string   `yaml:"image"`     // Image for a version, "{version}" is replaced with the version
+	Files     []string `yaml:"files"`     // Project files that request a version, the first one found is used
+	Versions  []string `yaml:"versions"`  // Versions with an image, the newest one in a requested range is used
+	Precision int      `yaml:"precision"` // Number of version components in the image tag, zero keeps all
+}
+
+// ArgRewrite inserts extra args after the first arg when it matches, e.g. "--conservative"
+// after "gem install"
+type ArgRewrite struct {
+	FirstArg string   `yaml:"first-arg"`
+	Insert   []string `yaml:"insert"`
+}
+
+// Default returns the registry shipped with asb
+func Default() *Registry {
+	registry, err := parse(_defaultRegistry)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded tool registry: %v", err))
+	}
+	return registry
+}
+
+func parse(data []byte) (*Registry, error) {
+	var registry Registry
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&registry); err != nil {
+		return nil, fmt.Errorf("failed to parse tool registry: %w", err)
+	}
+	return &registry, registry.Validate()
+}
+
+// Extend returns a registry with the tools and cache volumes of other added to this one.
+// The tools of other can neither replace nor copy the tools of this one, i.e. reuse their name, command,
+// image or cache volumes, as the policy applies to the tools by name and a copy under another name
+// would escape it.
+func (r *Registry) Extend(other *Registry) (*Registry, error) {
+	var errs []error
+	for _, volume := range other.CacheVolumes {
+		if _, found := r.CacheVolume(volume.Name); found {
+			errs = append(errs, fmt.Errorf("cache volume %q is built in and cannot be replaced", volume.Name))
+		}
+	}
+	for _, tool := range other.Tools {
+		errs = append(errs, r.checkNotCopied(tool))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	result := &Registry{
+		CacheVolumes: slices.Concat(r.CacheVolumes, other.CacheVolumes),
+		Tools:        slices.Concat(r.Tools, other.Tools),
+	}
+	return result, result.Validate()
+}
+
+// checkNotCopied returns an error if the tool replaces or copies one of the registry's tools
+func (r *Registry) checkNotCopied(tool Tool) error {
+	var errs []error
+	for _, existing := range r.Tools {
+		switch {
+		case tool.Name == existing.Name:
+			errs = append(errs, fmt.Errorf("tool %q is built in and cannot be replaced", tool.Name))
+		case tool.Command == existing.Command:
+			errs = append(errs, fmt.Errorf("command %q of tool %q is the one of the built-in tool %q",
+				tool.Command, tool.Name, existing.Name))
+		case slices.ContainsFunc(tool.imageRepositories(), func(repository string) bool {
+			return slices.Contains(existing.imageRepositories(), repository)
+		}):
+			errs = append(errs, fmt.Errorf("tool %q uses the image of the built-in tool %q, use that tool instead",
+				tool.Name, existing.Name))
+		case slices.ContainsFunc(tool.Caches, func(cache string) bool { return slices.Contains(existing.Caches, cache) }):
+			errs = append(errs, fmt.Errorf("tool %q uses a cache volume of the built-in tool %q, use that tool instead",
+				tool.Name, existing.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// imageRepositories returns the repositories of the tool's images, without the tag or digest
+func (t Tool) imageRepositories() []string {
+	repositories := []string{getImageRepository(t.Image)}
+	if t.Toolchain != nil {
+		repositories = append(repositories, getImageRepository(t.Toolchain.Image))
+	}
+	return repositories
+}
+
+// getImageRepository returns the repository of the image, e.g. "node" for "docker.io/library/node:25-bookworm"
+func getImageRepository(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if index := strings.LastIndex(image, ":"); index > strings.LastIndex(image, "/") {
+		image = image[:index]
+	}
+	image = strings.TrimPrefix(image, "docker.io/")
+	return strings.TrimPrefix(image, "library/")
+}
+
+// Tool returns the tool with the given name
+func (r *Registry) Tool(name string) (Tool, bool) {
+	index := slices.IndexFunc(r.Tools, func(tool Tool) bool { return tool.Name == name })
+	if index == -1 {
+		return Tool{}, false
+	}
+	return r.Tools[index], true
+}
+
+// CacheVolume returns the cache volume with the given name
+func (r *Registry) CacheVolume(name string) (CacheVolume, bool) {
+	index := slices.IndexFunc(r.CacheVolumes, func(volume CacheVolume) bool { return volume.Name == name })
+	if index == -1 {
+		return CacheVolume{}, false
+	}
+	return r.CacheVolumes[index], true
+}
+
+// ToolNames returns the names of all the tools
+func (r *Registry) ToolNames() []string {
+	names := make([]string, 0, len(r.Tools))
+	for _, tool := range r.Tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+// ToolsUsingCacheVolume returns the names of the tools that mount the given cache volume
+func (r *Registry) ToolsUsingCacheVolume(name string) []string {
+	names := make([]string, 0)
+	for _, tool := range r.Tools {
+		if slices.Contains(tool.Caches, name) {
+			names = append(names, tool.Name)
+		}
+	}
+	return names
+}
+
+// Validate checks that the names are unique and that the tools only refer to known cache volumes
+func (r *Registry) Validate() error {
+	var errs []error
+	volumeNames := make([]string, 0, len(r.CacheVolumes))
+	for _, volume := range r.CacheVolumes {
+		if !_volumeNameRegex.MatchString(volume.Name) {
+			errs = append(errs, fmt.Errorf("invalid cache volume name %q", volume.Name))
+		}
+		if volume.Target == "" {
+			errs = append(errs, fmt.Errorf("cache volume %q has no target", volume.Name))
+		}
+		if slices.Contains(volumeNames, volume.Name) {
+			errs = append(errs, fmt.Errorf("duplicate cache volume %q", volume.Name))
+		}
+		volumeNames = append(volumeNames, volume.Name)
+	}
+
+	toolNames := make([]string, 0, len(r.Tools))
+	commands := make([]string, 0, len(r.Tools))
+	for _, tool := range r.Tools {
+		if slices.Contains(toolNames, tool.Name) {
+			errs = append(errs, fmt.Errorf("duplicate tool %q", tool.Name))
+		}
+		if slices.Contains(commands, tool.Command) {
+			errs = append(errs, fmt.Errorf("duplicate tool command %q", tool.Command))
+		}
+		toolNames = append(toolNames, tool.Name)
+		commands = append(commands, tool.Command)
+		errs = append(errs, tool.validate(volumeNames))
+	}
+	return errors.Join(errs...)
+}
+
+func (t Tool) validate(volumeNames []string) error {
+	var errs []error
+	if !_toolNameRegex.MatchString(t.Name) {
+		errs = append(errs, fmt.Errorf("invalid tool name %q", t.Name))
+	}
+	if slices.Contains(_reservedToolNames, t.Name) {
+		errs = append(errs, fmt.Errorf("tool name %q is reserved", t.Name))
+	}
+	if !_commandRegex.MatchString(t.Command) {
+		errs = append(errs, fmt.Errorf("invalid command %q for tool %q", t.Command, t.Name))
+	}
+	if t.Image == "" {
+		errs = append(errs, fmt.Errorf("tool %q has no image", t.Name))
+	}
+	if !slices.Contains(_networkTypes, t.Network) {
+		errs = append(errs, fmt.Errorf("invalid network %q for tool %q, supported networks are %q",
+			t.Network, t.Name, _networkTypes[1:]))
+	}
+	if !slices.Contains(_diskAccesses, t.DiskAccess) {
+		errs = append(errs, fmt.Errorf("invalid disk-access %q for tool %q, supported values are %q",
+			t.DiskAccess, t.Name, _diskAccesses[1:]))
+	}
+	if t.PidsLimit < 0 {
+		errs = append(errs, fmt.Errorf("pids-limit of tool %q cannot be negative", t.Name))
+	}
+	if t.Memory != "" && !_memorySizeRegex.MatchString(t.Memory) {
+		errs = append(errs, fmt.Errorf("invalid memory %q of tool %q, expected a size like 512m or 2g", t.Memory, t.Name))
+	}
+	if t.CPUs < 0 || t.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("cpus and timeout of tool %q cannot be negative", t.Name))
+	}
+	for _, cache := range t.Caches {
+		if !slices.Contains(volumeNames, cache) {
+			errs = append(errs, fmt.Errorf("tool %q uses unknown cache volume %q", t.Name, cache))
+		}
+	}
+	if t.Toolchain != nil {
+		errs = append(errs, t.Toolchain.validate(t.Name))
+	}
+	for _, flag := range t.PathFlags {
+		if !strings.HasPrefix(flag, "-") || strings.Contains(flag, "=") {
+			errs = append(errs, fmt.Errorf("invalid path flag %q of tool %q", flag, t.Name))
+		}
+	}
+	for _, path := range t.WritablePaths {
+		if !filepath.IsLocal(path) || strings.HasPrefix(path, "~") {
+			errs = append(errs, fmt.Errorf("writable path %q of tool %q must be relative to the working directory",
+				path, t.Name))
+		}
+	}
+	for _, rewrite := range t.ArgRewrites {
+		if rewrite.FirstArg == "" {
+			errs = append(errs, fmt.Errorf("arg rewrite of tool %q has no first-arg", t.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t Toolchain) validate(toolName string) error {
+	var errs []error
+	if !strings.Contains(t.Image, "{version}") {
+		errs = append(errs, fmt.Errorf("toolchain image %q of tool %q has no {version}", t.Image, toolName))
+	}
+	for _, file := range t.Files {
+		if !toolchain.IsSupportedFile(file) {
+			errs = append(errs, fmt.Errorf("toolchain of tool %q cannot read the version from %q", toolName, file))
+		}
+	}
+	if t.Precision < 0 || t.Precision > 3 {
+		errs = append(errs, fmt.Errorf("toolchain precision of tool %q must be between 0 and 3", toolName))
+	}
+	return errors.Join(errs...)
+}
+
+// Args returns the command to run inside the container for the given user args
+func (t Tool) Args(args []string) []string {
+	for _, rewrite := range t.ArgRewrites {
+		if len(args) > 0 && args[0] == rewrite.FirstArg {
+			args = slices.Concat(args[:1], rewrite.Insert, args[1:])
+			break
+		}
+	}
+	return slices.Concat(t.CommandPrefix, args)
+}
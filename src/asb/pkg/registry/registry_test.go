@@ -0,0 +1,220 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default registry is invalid: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	volumes := []CacheVolume{{Name: "npm1", Target: "~/.npm"}}
+	valid := Tool{Name: "deno", Command: "deno", Image: "denoland/deno:debian", Caches: []string{"npm1"}}
+	tests := []struct {
+		name    string
+		volumes []CacheVolume
+		tools   []Tool
+		wantErr bool
+	}{
+		{name: "valid", volumes: volumes, tools: []Tool{valid}},
+		{name: "invalid cache volume name", volumes: []CacheVolume{{Name: "Npm", Target: "~/.npm"}}, wantErr: true},
+		{name: "cache volume without target", volumes: []CacheVolume{{Name: "npm1"}}, wantErr: true},
+		{name: "duplicate cache volume", volumes: append(volumes, volumes...), wantErr: true},
+		{name: "invalid tool name", volumes: volumes, tools: []Tool{with(valid, func(t *Tool) { t.Name = "Deno" })}, wantErr: true},
+		{name: "reserved tool name", volumes: volumes, tools: []Tool{with(valid, func(t *Tool) { t.Name = "run" })}, wantErr: true},
+		{name: "invalid command", volumes: volumes, tools: []Tool{with(valid, func(t *Tool) { t.Command = "deno run" })}, wantErr: true},
+		{name: "no image", volumes: volumes, tools: []Tool{with(valid, func(t *Tool) { t.Image = "" })}, wantErr: true},
+		{
+			name:    "duplicate tool",
+			volumes: volumes,
+			tools:   []Tool{valid, with(valid, func(t *Tool) { t.Command = "deno2" })},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate command",
+			volumes: volumes,
+			tools:   []Tool{valid, with(valid, func(t *Tool) { t.Name = "deno2" })},
+			wantErr: true,
+		},
+		{name: "unknown cache volume", tools: []Tool{valid}, wantErr: true},
+		{name: "invalid network", volumes: volumes, tools: []Tool{with(valid, func(t *Tool) { t.Network = "wifi" })}, wantErr: true},
+		{name: "proxy network", volumes: volumes, tools: []Tool{with(valid, func(t *Tool) { t.Network = "proxy" })}},
+		{
+			name:    "invalid disk access",
+			volumes: volumes,
+			tools:   []Tool{with(valid, func(t *Tool) { t.DiskAccess = "rw" })},
+			wantErr: true,
+		},
+		{name: "negative pids limit", volumes: volumes, tools: []Tool{with(valid, func(t *Tool) { t.PidsLimit = -1 })}, wantErr: true},
+		{name: "memory", volumes: volumes, tools: []Tool{with(valid, func(t *Tool) { t.Memory = "1.5g" })}},
+		{name: "invalid memory", volumes: volumes, tools: []Tool{with(valid, func(t *Tool) { t.Memory = "lots" })}, wantErr: true},
+		{name: "negative cpus", volumes: volumes, tools: []Tool{with(valid, func(t *Tool) { t.CPUs = -1 })}, wantErr: true},
+		{
+			name:    "negative timeout",
+			volumes: volumes,
+			tools:   []Tool{with(valid, func(t *Tool) { t.Timeout = -time.Minute })},
+			wantErr: true,
+		},
+		{
+			name:    "path flag without a dash",
+			volumes: volumes,
+			tools:   []Tool{with(valid, func(t *Tool) { t.PathFlags = []string{"config"} })},
+			wantErr: true,
+		},
+		{
+			name:    "path flag with a value",
+			volumes: volumes,
+			tools:   []Tool{with(valid, func(t *Tool) { t.PathFlags = []string{"--config=x"} })},
+			wantErr: true,
+		},
+		{
+			name:    "writable path",
+			volumes: volumes,
+			tools:   []Tool{with(valid, func(t *Tool) { t.WritablePaths = []string{"node_modules", "a/b"} })},
+		},
+		{
+			name:    "writable path outside",
+			volumes: volumes,
+			tools:   []Tool{with(valid, func(t *Tool) { t.WritablePaths = []string{"../x"} })},
+			wantErr: true,
+		},
+		{
+			name:    "absolute writable path",
+			volumes: volumes,
+			tools:   []Tool{with(valid, func(t *Tool) { t.WritablePaths = []string{"/tmp"} })},
+			wantErr: true,
+		},
+		{
+			name:    "writable path in the home directory",
+			volumes: volumes,
+			tools:   []Tool{with(valid, func(t *Tool) { t.WritablePaths = []string{"~/x"} })},
+			wantErr: true,
+		},
+		{
+			name:    "arg rewrite without first arg",
+			volumes: volumes,
+			tools:   []Tool{with(valid, func(t *Tool) { t.ArgRewrites = []ArgRewrite{{Insert: []string{"--frozen"}}} })},
+			wantErr: true,
+		},
+		{
+			name:    "toolchain",
+			volumes: volumes,
+			tools: []Tool{with(valid, func(t *Tool) {
+				t.Toolchain = &Toolchain{Image: "denoland/deno:{version}", Files: []string{".node-version"}, Precision: 2}
+			})},
+		},
+		{
+			name:    "toolchain image without version",
+			volumes: volumes,
+			tools:   []Tool{with(valid, func(t *Tool) { t.Toolchain = &Toolchain{Image: "denoland/deno:latest"} })},
+			wantErr: true,
+		},
+		{
+			name:    "toolchain file not supported",
+			volumes: volumes,
+			tools: []Tool{with(valid, func(t *Tool) {
+				t.Toolchain = &Toolchain{Image: "denoland/deno:{version}", Files: []string{"deno.json"}}
+			})},
+			wantErr: true,
+		},
+		{
+			name:    "toolchain precision out of range",
+			volumes: volumes,
+			tools:   []Tool{with(valid, func(t *Tool) { t.Toolchain = &Toolchain{Image: "denoland/deno:{version}", Precision: 4} })},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := &Registry{CacheVolumes: tt.volumes, Tools: tt.tools}
+			if err := registry.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtend(t *testing.T) {
+	base := &Registry{
+		CacheVolumes: []CacheVolume{{Name: "npm1", Target: "~/.npm"}},
+		Tools: []Tool{{
+			Name:      "node_npx",
+			Command:   "npx",
+			Image:     "node:25-bookworm",
+			Caches:    []string{"npm1"},
+			Toolchain: &Toolchain{Image: "node:{version}-bookworm"},
+		}},
+	}
+	deno := Tool{Name: "deno", Command: "deno", Image: "denoland/deno:debian"}
+	tests := []struct {
+		name    string
+		volumes []CacheVolume
+		tools   []Tool
+		wantErr bool
+	}{
+		{name: "new tool", tools: []Tool{deno}},
+		{
+			name:    "new tool with a new cache volume",
+			volumes: []CacheVolume{{Name: "deno1", Target: "~/.cache/deno"}},
+			tools:   []Tool{with(deno, func(t *Tool) { t.Caches = []string{"deno1"} })},
+		},
+		{name: "replaced tool", tools: []Tool{with(deno, func(t *Tool) { t.Name = "node_npx" })}, wantErr: true},
+		{name: "command of a tool", tools: []Tool{with(deno, func(t *Tool) { t.Command = "npx" })}, wantErr: true},
+		{name: "image of a tool", tools: []Tool{with(deno, func(t *Tool) { t.Image = "node:22-alpine" })}, wantErr: true},
+		{
+			name:    "image of a tool with the registry",
+			tools:   []Tool{with(deno, func(t *Tool) { t.Image = "docker.io/library/node:25-bookworm" })},
+			wantErr: true,
+		},
+		{
+			name:    "image of a tool by digest",
+			tools:   []Tool{with(deno, func(t *Tool) { t.Image = "node@sha256:0123456789abcdef" })},
+			wantErr: true,
+		},
+		{
+			name: "toolchain image of a tool",
+			tools: []Tool{with(deno, func(t *Tool) {
+				t.Toolchain = &Toolchain{Image: "docker.io/node:{version}-alpine"}
+			})},
+			wantErr: true,
+		},
+		{
+			name:  "image of another repository",
+			tools: []Tool{with(deno, func(t *Tool) { t.Image = "localhost:5000/node:25" })},
+		},
+		{name: "cache volume of a tool", tools: []Tool{with(deno, func(t *Tool) { t.Caches = []string{"npm1"} })}, wantErr: true},
+		{name: "replaced cache volume", volumes: []CacheVolume{{Name: "npm1", Target: "~/.cache/npm"}}, wantErr: true},
+		{name: "invalid new tool", tools: []Tool{with(deno, func(t *Tool) { t.Network = "wifi" })}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extended, err := base.Extend(&Registry{CacheVolumes: tt.volumes, Tools: tt.tools})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Extend() error = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for _, tool := range tt.tools {
+				if _, found := extended.Tool(tool.Name); !found {
+					t.Errorf("Extended registry has no tool %q", tool.Name)
+				}
+			}
+			if _, found := extended.Tool("node_npx"); !found {
+				t.Errorf("Extended registry lost the tool %q", "node_npx")
+			}
+		})
+	}
+}
+
+// with returns a copy of the tool changed by update
+func with(tool Tool, update func(*Tool)) Tool {
+	update(&tool)
+	return tool
+}